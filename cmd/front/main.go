@@ -3,18 +3,27 @@ package main
 import (
 	"context"
 	"errors"
+	"expvar"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/itimofeev/yas3/internal/entity"
+	"github.com/itimofeev/yas3/internal/provider/auth"
+	"github.com/itimofeev/yas3/internal/provider/bandwidth"
+	"github.com/itimofeev/yas3/internal/provider/encryption"
 	fileregistry "github.com/itimofeev/yas3/internal/provider/file-registry"
+	"github.com/itimofeev/yas3/internal/provider/repair"
 	serverRegistry "github.com/itimofeev/yas3/internal/provider/server-registry"
+	"github.com/itimofeev/yas3/internal/provider/tlsconfig"
 	"github.com/itimofeev/yas3/internal/server/front"
 )
 
@@ -26,6 +35,54 @@ type configuration struct {
 	StoreServerAddrs  []string      `envconfig:"FRONT_STORE_CLIENT_ADDR" default:"https://localhost:9090"`
 	FilesDBPath       string        `envconfig:"FRONT_FILES_DB_PATH" default:"temp/store/badger"`
 	FilePartsCount    int64         `envconfig:"FRONT_FILE_PARTS_COUNT" default:"2"`
+	PlacementStrategy string        `envconfig:"FRONT_PLACEMENT_STRATEGY" default:"least-loaded"`
+
+	// StoreTLS* are CA of store servers and client certificate of front, every value is path to PEM file or PEM content.
+	StoreTLSCA   string `envconfig:"FRONT_STORE_TLS_CA" default:"temp/certs/ca.pem"`
+	StoreTLSCert string `envconfig:"FRONT_STORE_TLS_CERT" default:"temp/certs/front.pem"`
+	StoreTLSKey  string `envconfig:"FRONT_STORE_TLS_KEY" default:"temp/certs/front.key"`
+	// StoreTokenSecret is shared with store servers and signs token of every request to them.
+	StoreTokenSecret string `envconfig:"FRONT_STORE_TOKEN_SECRET"`
+
+	StoreProbeInterval          time.Duration `envconfig:"FRONT_STORE_PROBE_INTERVAL" default:"2s"`
+	StoreProbeTimeout           time.Duration `envconfig:"FRONT_STORE_PROBE_TIMEOUT" default:"1s"`
+	StoreBreakerErrorsThreshold int           `envconfig:"FRONT_STORE_BREAKER_ERRORS_THRESHOLD" default:"5"`
+	StoreBreakerBackoff         time.Duration `envconfig:"FRONT_STORE_BREAKER_BACKOFF" default:"1s"`
+
+	// Bandwidth* are caps of traffic to store servers in bytes per second, zero means unlimited, BandwidthStoreCaps override per store cap.
+	BandwidthTotalBytesPerSecond      int64    `envconfig:"FRONT_BANDWIDTH_TOTAL_BYTES_PER_SECOND" default:"0"`
+	BandwidthStoreBytesPerSecond      int64    `envconfig:"FRONT_BANDWIDTH_STORE_BYTES_PER_SECOND" default:"0"`
+	BandwidthStoreCaps                []string `envconfig:"FRONT_BANDWIDTH_STORE_CAPS"`
+	BandwidthBackgroundBytesPerSecond int64    `envconfig:"FRONT_BANDWIDTH_BACKGROUND_BYTES_PER_SECOND" default:"0"`
+
+	ReplicationFactor      int           `envconfig:"FRONT_REPLICATION_FACTOR" default:"1"`
+	RepairScanInterval     time.Duration `envconfig:"FRONT_REPAIR_SCAN_INTERVAL" default:"1m"`
+	RepairOfflineThreshold time.Duration `envconfig:"FRONT_REPAIR_OFFLINE_THRESHOLD" default:"10m"`
+	RepairWorkers          int           `envconfig:"FRONT_REPAIR_WORKERS" default:"2"`
+
+	// EncryptionKeyFile is path to master key, new files are stored in plaintext when it is empty.
+	EncryptionKeyFile string `envconfig:"FRONT_ENCRYPTION_KEY_FILE"`
+
+	// AuthRootAccessKey and AuthRootSecretKey are saved to file registry at start with admin policy, so the first key exists.
+	AuthEnabled       bool          `envconfig:"FRONT_AUTH_ENABLED" default:"false"`
+	AuthRootAccessKey string        `envconfig:"FRONT_AUTH_ROOT_ACCESS_KEY"`
+	AuthRootSecretKey string        `envconfig:"FRONT_AUTH_ROOT_SECRET_KEY"`
+	AuthMaxClockSkew  time.Duration `envconfig:"FRONT_AUTH_MAX_CLOCK_SKEW" default:"5m"`
+
+	// TLSCert and TLSKey enable HTTPS with HTTP/2 and HTTP/3, they are paths to PEM files or PEM content.
+	TLSCert string `envconfig:"FRONT_TLS_CERT"`
+	TLSKey  string `envconfig:"FRONT_TLS_KEY"`
+
+	// RateLimit* are limits of every client identified by access key or IP address, zero disables limit.
+	RateLimitRequestsPerSecond   float64 `envconfig:"FRONT_RATE_LIMIT_RPS" default:"0"`
+	RateLimitRequestsBurst       int     `envconfig:"FRONT_RATE_LIMIT_BURST" default:"10"`
+	RateLimitConcurrentUploads   int     `envconfig:"FRONT_RATE_LIMIT_CONCURRENT_UPLOADS" default:"0"`
+	RateLimitConcurrentDownloads int     `envconfig:"FRONT_RATE_LIMIT_CONCURRENT_DOWNLOADS" default:"0"`
+	RateLimitBytesPerSecond      int     `envconfig:"FRONT_RATE_LIMIT_BYTES_PER_SECOND" default:"0"`
+
+	// PresignSecret enables presigned URLs.
+	PresignSecret    string        `envconfig:"FRONT_PRESIGN_SECRET"`
+	PresignMaxExpiry time.Duration `envconfig:"FRONT_PRESIGN_MAX_EXPIRY" default:"24h"`
 }
 
 func main() {
@@ -42,10 +99,45 @@ func main() {
 func run(cfg configuration) error {
 	ctx := signalContext()
 
-	storeServersRegistry, err := serverRegistry.New(ctx, serverRegistry.Config{StoreServerAddrs: cfg.StoreServerAddrs})
+	storeTLSConfig, err := tlsconfig.Client(tlsconfig.Config{
+		CertFile: cfg.StoreTLSCert,
+		KeyFile:  cfg.StoreTLSKey,
+		CAFile:   cfg.StoreTLSCA,
+	})
+	if err != nil {
+		return err
+	}
+
+	storeCaps, err := parseStoreCaps(cfg.BandwidthStoreCaps)
+	if err != nil {
+		return err
+	}
+	bandwidthScheduler, err := bandwidth.New(bandwidth.Config{
+		TotalBytesPerSecond:      cfg.BandwidthTotalBytesPerSecond,
+		StoreBytesPerSecond:      cfg.BandwidthStoreBytesPerSecond,
+		StoreCaps:                storeCaps,
+		BackgroundBytesPerSecond: cfg.BandwidthBackgroundBytesPerSecond,
+	})
+	if err != nil {
+		return err
+	}
+	expvar.Publish("bandwidth", expvar.Func(bandwidthScheduler.Metrics))
+
+	storeServersRegistry, err := serverRegistry.New(ctx, serverRegistry.Config{
+		StoreServerAddrs:       cfg.StoreServerAddrs,
+		StoreTLSConfig:         storeTLSConfig,
+		StoreTokenSecret:       cfg.StoreTokenSecret,
+		Bandwidth:              bandwidthScheduler,
+		PlacementStrategy:      cfg.PlacementStrategy,
+		ProbeInterval:          cfg.StoreProbeInterval,
+		ProbeTimeout:           cfg.StoreProbeTimeout,
+		BreakerErrorsThreshold: cfg.StoreBreakerErrorsThreshold,
+		BreakerBackoff:         cfg.StoreBreakerBackoff,
+	})
 	if err != nil {
 		return err
 	}
+	expvar.Publish("storeServers", expvar.Func(storeServersRegistry.Metrics))
 
 	fileRegistry, err := fileregistry.New(fileregistry.Config{
 		DBPath: cfg.FilesDBPath,
@@ -55,18 +147,73 @@ func run(cfg configuration) error {
 	}
 	defer fileRegistry.Close()
 
-	frontServer, err := front.New(front.Config{
-		Addr:             cfg.FrontAddr,
-		ReadTimeout:      cfg.FrontReadTimeout,
-		WriteTimeout:     cfg.FrontWriteTimeout,
-		MaxFileSizeBytes: 1024 * 1024,
-		PartsCount:       cfg.FilePartsCount,
+	frontCfg := front.Config{
+		Addr:              cfg.FrontAddr,
+		ReadTimeout:       cfg.FrontReadTimeout,
+		WriteTimeout:      cfg.FrontWriteTimeout,
+		MaxFileSizeBytes:  1024 * 1024,
+		PartsCount:        cfg.FilePartsCount,
+		ReplicationFactor: cfg.ReplicationFactor,
+		ServersRegistry:   storeServersRegistry,
+		FileRegistry:      fileRegistry,
+		PresignSecret:     cfg.PresignSecret,
+		PresignMaxExpiry:  cfg.PresignMaxExpiry,
+		RateLimits: front.RateLimits{
+			RequestsPerSecond:   cfg.RateLimitRequestsPerSecond,
+			RequestsBurst:       cfg.RateLimitRequestsBurst,
+			ConcurrentUploads:   cfg.RateLimitConcurrentUploads,
+			ConcurrentDownloads: cfg.RateLimitConcurrentDownloads,
+			BytesPerSecond:      cfg.RateLimitBytesPerSecond,
+		},
+	}
+	if cfg.TLSCert != "" {
+		tlsConfig, err := tlsconfig.Server(tlsconfig.Config{
+			CertFile: cfg.TLSCert,
+			KeyFile:  cfg.TLSKey,
+		})
+		if err != nil {
+			return err
+		}
+		frontCfg.TLSConfig = tlsConfig
+	}
+	if cfg.EncryptionKeyFile != "" {
+		keyWrapper, err := encryption.New(encryption.Config{
+			KeyFilePath: cfg.EncryptionKeyFile,
+		})
+		if err != nil {
+			return err
+		}
+		frontCfg.KeyWrapper = keyWrapper
+	}
+	if cfg.AuthEnabled {
+		if cfg.AuthRootAccessKey != "" {
+			if cfg.AuthRootSecretKey == "" {
+				return errors.New("root secret key is required with root access key")
+			}
+			if err := createRootKey(fileRegistry, cfg.AuthRootAccessKey, cfg.AuthRootSecretKey); err != nil {
+				return err
+			}
+		}
+		frontCfg.AccessKeys = fileRegistry
+		frontCfg.AuthMaxClockSkew = cfg.AuthMaxClockSkew
+	}
+
+	frontServer, err := front.New(frontCfg)
+	if err != nil {
+		return err
+	}
+
+	repairer, err := repair.New(repair.Config{
+		ScanInterval:     cfg.RepairScanInterval,
+		OfflineThreshold: cfg.RepairOfflineThreshold,
+		Workers:          cfg.RepairWorkers,
 		ServersRegistry:  storeServersRegistry,
 		FileRegistry:     fileRegistry,
 	})
 	if err != nil {
 		return err
 	}
+	expvar.Publish("repair", expvar.Func(repairer.Metrics))
 
 	eg, ctx := errgroup.WithContext(ctx)
 
@@ -76,6 +223,9 @@ func run(cfg configuration) error {
 	eg.Go(func() error {
 		return storeServersRegistry.Run(ctx)
 	})
+	eg.Go(func() error {
+		return repairer.Run(ctx)
+	})
 
 	return eg.Wait()
 }
@@ -96,6 +246,23 @@ func signalContext() context.Context {
 	return ctx
 }
 
+// parseStoreCaps parses caps of store servers in form address=bytes.
+func parseStoreCaps(caps []string) (map[string]int64, error) {
+	storeCaps := make(map[string]int64, len(caps))
+	for _, storeCap := range caps {
+		addr, bytesStr, ok := strings.Cut(storeCap, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid store bandwidth cap %q, expected address=bytes", storeCap)
+		}
+		bytesPerSecond, err := strconv.ParseInt(bytesStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid store bandwidth cap %q: %w", storeCap, err)
+		}
+		storeCaps[addr] = bytesPerSecond
+	}
+	return storeCaps, nil
+}
+
 func mustParseConfig() configuration {
 	var cfg configuration
 	if err := envconfig.Process("", &cfg); err != nil {
@@ -103,3 +270,21 @@ func mustParseConfig() configuration {
 	}
 	return cfg
 }
+
+// createRootKey creates root access key with admin policy when it doesn't exist, existing key is left as is.
+func createRootKey(fileRegistry *fileregistry.Registry, accessKey, secretKey string) error {
+	_, err := fileRegistry.GetAccessKey(accessKey)
+	if err == nil {
+		slog.Info("root access key exists, configured secret key is not applied", "accessKey", accessKey)
+		return nil
+	}
+	if !errors.Is(err, entity.ErrAccessKeyNotFound) {
+		return err
+	}
+
+	return fileRegistry.SaveAccessKeyWithPolicy(entity.AccessKey{AccessKey: accessKey, SecretKey: secretKey}, entity.Policy{
+		Admin:      true,
+		Operations: []string{auth.OperationUpload, auth.OperationGet},
+		Prefixes:   []string{""},
+	})
+}
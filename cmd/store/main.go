@@ -8,18 +8,39 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/itimofeev/yas3/internal/provider/tlsconfig"
 	"github.com/itimofeev/yas3/internal/server/store"
 )
 
 // STORE_SERVER_ADDR=:9090;STORE_BASE_PATH=temp/store/1
 type configuration struct {
 	StoreServerAddr     string `envconfig:"STORE_SERVER_ADDR" default:":9090"`
+	StoreListenTCP      bool   `envconfig:"STORE_LISTEN_TCP" default:"true"` // TCP listener on the same port for clients without UDP
+	StoreGRPCAddr       string `envconfig:"STORE_GRPC_ADDR" default:":9190"` // empty value disables gRPC listener
 	StoreBasePath       string `envconfig:"STORE_BASE_PATH" default:"temp/store/1"`
 	StoreTotalSizeBytes int    `envconfig:"STORE_TOTAL_SIZE_BYTES" default:"1073741824"` // 1Gb
+	StoreZone           string `envconfig:"STORE_ZONE"`
+	StoreRack           string `envconfig:"STORE_RACK"`
+	StoreHost           string `envconfig:"STORE_HOST"`
+	StoreReadOnly       bool   `envconfig:"STORE_READ_ONLY" default:"false"`
+	StoreCompression    string `envconfig:"STORE_COMPRESSION" default:"none"`
+
+	// StoreTLS* are paths to PEM files or PEM content, client certificates are not required when client CA is empty.
+	StoreTLSCert     string `envconfig:"STORE_TLS_CERT" default:"temp/certs/store.pem"`
+	StoreTLSKey      string `envconfig:"STORE_TLS_KEY" default:"temp/certs/store.key"`
+	StoreTLSClientCA string `envconfig:"STORE_TLS_CLIENT_CA" default:"temp/certs/ca.pem"`
+	// StoreTokenSecret is shared with front, every request has to have token signed with it when it is set.
+	StoreTokenSecret string `envconfig:"STORE_TOKEN_SECRET"`
+	// StoreTokenAudience lists addresses of the store in FRONT_STORE_CLIENT_ADDR, for example https://store0:9090,grpc://store0:9190.
+	StoreTokenAudience []string `envconfig:"STORE_TOKEN_AUDIENCE"`
+
+	StoreScrubRateBytes int64         `envconfig:"STORE_SCRUB_RATE_BYTES" default:"10485760"` // 10Mb/s
+	StoreScrubInterval  time.Duration `envconfig:"STORE_SCRUB_INTERVAL" default:"24h"`
 }
 
 func main() {
@@ -36,10 +57,31 @@ func main() {
 func run(cfg configuration) error {
 	ctx := signalContext()
 
+	tlsConfig, err := tlsconfig.Server(tlsconfig.Config{
+		CertFile: cfg.StoreTLSCert,
+		KeyFile:  cfg.StoreTLSKey,
+		CAFile:   cfg.StoreTLSClientCA,
+	})
+	if err != nil {
+		return err
+	}
+
 	storeServer, err := store.New(store.Config{
 		Addr:                   cfg.StoreServerAddr,
+		ListenTCP:              cfg.StoreListenTCP,
+		GRPCAddr:               cfg.StoreGRPCAddr,
 		BasePath:               cfg.StoreBasePath,
 		MaxAvailableSpaceBytes: cfg.StoreTotalSizeBytes,
+		TLSConfig:              tlsConfig,
+		TokenSecret:            cfg.StoreTokenSecret,
+		TokenAudiences:         cfg.StoreTokenAudience,
+		Zone:                   cfg.StoreZone,
+		Rack:                   cfg.StoreRack,
+		Host:                   cfg.StoreHost,
+		ReadOnly:               cfg.StoreReadOnly,
+		Compression:            cfg.StoreCompression,
+		ScrubRateBytes:         cfg.StoreScrubRateBytes,
+		ScrubInterval:          cfg.StoreScrubInterval,
 	})
 	if err != nil {
 		return err
@@ -50,6 +92,9 @@ func run(cfg configuration) error {
 	eg.Go(func() error {
 		return storeServer.Run(ctx)
 	})
+	eg.Go(func() error {
+		return storeServer.RunScrubber(ctx)
+	})
 
 	return eg.Wait()
 }
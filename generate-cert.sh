@@ -2,22 +2,33 @@
 
 set -e
 
+# certificates are written to temp/certs, which is mounted to containers by docker-compose
+CERTS_DIR=${CERTS_DIR:-temp/certs}
+mkdir -p "$CERTS_DIR"
+cd "$CERTS_DIR"
+
 echo "Generating CA key and certificate:"
 openssl req -x509 -sha256 -nodes -days 3650 -newkey rsa:2048 \
   -keyout ca.key -out ca.pem \
-  -subj "/O=quic-go Certificate Authority/"
+  -subj "/O=yas3 Certificate Authority/"
 
-echo "Generating CSR"
-openssl req -out cert.csr -new -newkey rsa:2048 -nodes -keyout private.key \
-  -subj "/O=quic-go/"
+echo "Generating store server certificate:"
+openssl req -out store.csr -new -newkey rsa:2048 -nodes -keyout store.key \
+  -subj "/O=yas3/CN=store/"
+openssl x509 -req -sha256 -days 3650 -in store.csr -out store.pem \
+  -CA ca.pem -CAkey ca.key -CAcreateserial \
+  -extfile <(printf "subjectAltName=DNS:localhost,DNS:store0,DNS:store1,DNS:store2\nextendedKeyUsage=serverAuth")
 
-echo "Sign certificate:"
-openssl x509 -req -sha256 -days 3650 -in cert.csr  -out cert.pem \
+echo "Generating front client certificate:"
+openssl req -out front.csr -new -newkey rsa:2048 -nodes -keyout front.key \
+  -subj "/O=yas3/CN=front/"
+openssl x509 -req -sha256 -days 3650 -in front.csr -out front.pem \
   -CA ca.pem -CAkey ca.key -CAcreateserial \
-  -extfile <(printf "subjectAltName=DNS:localhost")
+  -extfile <(printf "extendedKeyUsage=clientAuth")
 
-# debug output the certificate
-openssl x509 -noout -text -in cert.pem
+# debug output the certificates
+openssl x509 -noout -subject -ext subjectAltName,extendedKeyUsage -in store.pem
+openssl x509 -noout -subject -ext extendedKeyUsage -in front.pem
 
-# we don't need the CA key, the serial number and the CSR any more
-rm ca.key cert.csr ca.srl
\ No newline at end of file
+# we don't need the CA key, the serial number and the CSRs any more
+rm ca.key store.csr front.csr ca.srl
@@ -2,17 +2,138 @@ package entity
 
 import (
 	"context"
+	"errors"
 	"io"
+	"net/http"
+	"strconv"
+	"time"
 )
 
+var (
+	ErrFileNotFound = errors.New("file not found")
+	// ErrFileChanged is returned by range request when content of file doesn't match ETag of previous request.
+	ErrFileChanged       = errors.New("file content changed")
+	ErrAccessKeyNotFound = errors.New("access key not found")
+	ErrPolicyNotFound    = errors.New("policy not found")
+)
+
+// StoreResponseError is error response of store server, status codes of gRPC are mapped to HTTP ones.
+type StoreResponseError struct {
+	StatusCode int
+}
+
+func (e *StoreResponseError) Error() string {
+	return "response code not 200: " + strconv.Itoa(e.StatusCode)
+}
+
+// ServerFault reports if store server failed to handle request, rejections like read-only mode or exceeded quota are not faults.
+func (e *StoreResponseError) ServerFault() bool {
+	return e.StatusCode >= http.StatusInternalServerError && e.StatusCode != http.StatusInsufficientStorage
+}
+
+// AccessKey is credential of front API client, requests are signed with its secret key.
+type AccessKey struct {
+	AccessKey string
+	SecretKey string
+	// Disabled key is known, but its requests are rejected.
+	Disabled bool
+}
+
+// Policy lists what client with access key is allowed to do, everything not listed is denied.
+type Policy struct {
+	// Admin allows to manage access keys and policies.
+	Admin bool
+	// ReadOnly denies uploads.
+	ReadOnly bool
+	// Operations allowed for the key.
+	Operations []string
+	// Prefixes of file ids which the key can access.
+	Prefixes []string
+}
+
 type AvailableSpace struct {
+	// Total is space which can be used now, it is less than Capacity when file system has less free space than quota allows.
 	Total int64
 	Used  int64
+	// Capacity is configured quota of store server, it doesn't change with usage of file system.
+	Capacity int64
+	// LogicalUsed is size of stored files before compression, Used is their size on disk.
+	LogicalUsed int64
+}
+
+// FailureDomain describes where store server is located. Servers with the same labels can fail together.
+type FailureDomain struct {
+	Zone string
+	Rack string
+	Host string
+}
+
+type StoreStats struct {
+	Space       AvailableSpace
+	FilesCount  int64
+	InodesTotal int64
+	InodesFree  int64
+	Labels      FailureDomain
+	// ReadOnly is true when store server rejects uploads, but still serves files.
+	ReadOnly bool
+	// CorruptedFiles are files which content doesn't match checksum written at upload time.
+	CorruptedFiles []string
+}
+
+// FileMeta is information about uploaded file kept in file registry.
+type FileMeta struct {
+	Parts []FilePart
+	// Encryption is nil for files stored in plaintext.
+	Encryption *Encryption
+}
+
+// Encryption describes how file parts are encrypted on store servers.
+type Encryption struct {
+	// Scheme defines cipher and nonces of encrypted chunks of file parts.
+	Scheme    string
+	ChunkSize int
+	// WrappedKey is random data key of the file encrypted with master key, or with customer key when KeyFingerprint is set.
+	WrappedKey []byte
+	// KeyFingerprint identifies key provided by customer, customer key itself is never stored.
+	KeyFingerprint string
+}
+
+// FilePart lists store servers which keep replicas of file part.
+type FilePart struct {
+	ServerIDs []string
+}
+
+// PartFileName returns name of file part on store servers.
+func PartFileName(fileID string, partNumber int) string {
+	return fileID + "." + strconv.Itoa(partNumber)
+}
+
+// FileInfo describes file part stored on store server.
+type FileInfo struct {
+	Size    int64
+	ModTime time.Time
+	// Checksum is hex encoded sha256 of file content.
+	Checksum string
+}
+
+// FileContent is content of file or its range read from store server.
+type FileContent struct {
+	io.ReadCloser
+	// Size is size of the whole file, not only of the range.
+	Size int64
+	// ETag identifies content of file, it is based on checksum, so replicas of file have the same ETag.
+	ETag string
 }
 
 type StoreClient interface {
 	GetID() string
 	UploadFile(ctx context.Context, fileName string, content io.Reader) error
 	GetFile(ctx context.Context, fileName string) (io.ReadCloser, error)
-	GetAvailableSpace(ctx context.Context) (AvailableSpace, error)
+	// GetFileRange returns length bytes of file from offset (negative is till the end), or ErrFileChanged if non-empty ifRange isn't ETag of file.
+	GetFileRange(ctx context.Context, fileName string, offset, length int64, ifRange string) (*FileContent, error)
+	DeleteFile(ctx context.Context, fileName string) error
+	StatFile(ctx context.Context, fileName string) (FileInfo, error)
+	// ListFiles returns up to limit file names which follow after in stable, but not lexical, listing order of store server.
+	ListFiles(ctx context.Context, after string, limit int) ([]string, error)
+	GetStats(ctx context.Context) (StoreStats, error)
 }
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/itimofeev/yas3/internal/entity"
+)
+
+// Operations of front API restricted by policies.
+const (
+	OperationUpload = "upload"
+	OperationGet    = "get"
+)
+
+// Allowed reports if policy allows operation on file, nil policy allows nothing and empty prefix matches any file.
+func Allowed(policy *entity.Policy, operation, fileID string) bool {
+	if policy == nil {
+		return false
+	}
+	if policy.ReadOnly && operation == OperationUpload {
+		return false
+	}
+	if !slices.Contains(policy.Operations, operation) {
+		return false
+	}
+	return slices.ContainsFunc(policy.Prefixes, func(prefix string) bool {
+		return strings.HasPrefix(fileID, prefix)
+	})
+}
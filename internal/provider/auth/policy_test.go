@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/itimofeev/yas3/internal/entity"
+)
+
+func TestAllowed(t *testing.T) {
+	all := &entity.Policy{Operations: []string{OperationUpload, OperationGet}, Prefixes: []string{""}}
+	tests := []struct {
+		name      string
+		policy    *entity.Policy
+		operation string
+		fileID    string
+		allowed   bool
+	}{
+		{name: "nil policy", policy: nil, operation: OperationGet, fileID: "a", allowed: false},
+		{name: "empty policy", policy: &entity.Policy{}, operation: OperationGet, fileID: "a", allowed: false},
+		{name: "admin only", policy: &entity.Policy{Admin: true}, operation: OperationGet, fileID: "a", allowed: false},
+		{name: "no prefixes", policy: &entity.Policy{Operations: []string{OperationGet}}, operation: OperationGet, fileID: "a", allowed: false},
+		{name: "all", policy: all, operation: OperationUpload, fileID: "a", allowed: true},
+		{name: "operation not listed", policy: &entity.Policy{Operations: []string{OperationGet}, Prefixes: []string{""}}, operation: OperationUpload, fileID: "a", allowed: false},
+		{name: "read only", policy: &entity.Policy{ReadOnly: true, Operations: all.Operations, Prefixes: all.Prefixes}, operation: OperationUpload, fileID: "a", allowed: false},
+		{name: "prefix matches", policy: &entity.Policy{Operations: []string{OperationGet}, Prefixes: []string{"x", "ab"}}, operation: OperationGet, fileID: "abc", allowed: true},
+		{name: "prefix doesn't match", policy: &entity.Policy{Operations: []string{OperationGet}, Prefixes: []string{"x"}}, operation: OperationGet, fileID: "abc", allowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.allowed, Allowed(tt.policy, tt.operation, tt.fileID))
+		})
+	}
+}
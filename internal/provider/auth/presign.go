@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Query parameters of presigned URL.
+const (
+	PresignExpiresParam   = "expires"
+	PresignMaxSizeParam   = "maxSize"
+	PresignSignatureParam = "signature"
+)
+
+// Presign returns query parameters which allow method on file without credentials until expires, positive maxSize limits upload.
+func Presign(secret, method, fileID string, expires, maxSize int64) url.Values {
+	query := url.Values{}
+	query.Set(PresignExpiresParam, strconv.FormatInt(expires, 10))
+	if maxSize > 0 {
+		query.Set(PresignMaxSizeParam, strconv.FormatInt(maxSize, 10))
+	}
+	query.Set(PresignSignatureParam, PresignSignature(secret, method, fileID, expires, maxSize))
+	return query
+}
+
+// PresignSignature returns hex encoded HMAC-SHA256 of presigned request parameters.
+func PresignSignature(secret, method, fileID string, expires, maxSize int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{
+		method, fileID, strconv.FormatInt(expires, 10), strconv.FormatInt(maxSize, 10),
+	}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
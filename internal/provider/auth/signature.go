@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itimofeev/yas3/internal/entity"
+)
+
+// Headers of signed request.
+const (
+	AccessKeyHeader = "X-Access-Key"
+	// TimestampHeader is unix time in seconds when request was signed, requests signed too long ago are rejected to prevent replay.
+	TimestampHeader = "X-Timestamp"
+	SignatureHeader = "X-Signature"
+	// ContentSha256Header is hex encoded sha256 of request body or UnsignedPayload, front verifies body against it.
+	ContentSha256Header = "X-Content-Sha256"
+)
+
+// UnsignedPayload is value of ContentSha256Header of request which body is not covered by signature.
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// SignedHeaders are headers of request covered by signature, missing header is signed as empty value.
+var SignedHeaders = []string{"X-Customer-Key", ContentSha256Header}
+
+// Sign returns hex encoded HMAC-SHA256 of request method, host, path, query, timestamp and signed headers with secret key.
+func Sign(secretKey string, req *http.Request, timestamp int64) string {
+	parts := []string{req.Method, req.Host, req.URL.EscapedPath(), req.URL.RawQuery, strconv.FormatInt(timestamp, 10)}
+	for _, header := range SignedHeaders {
+		parts = append(parts, req.Header.Get(header))
+	}
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest sets authentication headers of request, request with body and without content hash is signed as UnsignedPayload.
+func SignRequest(req *http.Request, accessKey, secretKey string, now time.Time) {
+	if req.Header.Get(ContentSha256Header) == "" {
+		if req.Body == nil || req.Body == http.NoBody {
+			req.Header.Set(ContentSha256Header, ContentSha256(nil))
+		} else {
+			req.Header.Set(ContentSha256Header, UnsignedPayload)
+		}
+	}
+	timestamp := now.Unix()
+	req.Header.Set(AccessKeyHeader, accessKey)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(secretKey, req, timestamp))
+}
+
+// ContentSha256 returns value of ContentSha256Header for body.
+func ContentSha256(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// SignatureMatches compares signatures in constant time.
+func SignatureMatches(a, b string) bool {
+	return hmac.Equal([]byte(a), []byte(b))
+}
+
+// NewAccessKey generates random access key and secret key.
+func NewAccessKey() (entity.AccessKey, error) {
+	accessKey := make([]byte, 10)
+	if _, err := rand.Read(accessKey); err != nil {
+		return entity.AccessKey{}, err
+	}
+	secretKey := make([]byte, 32)
+	if _, err := rand.Read(secretKey); err != nil {
+		return entity.AccessKey{}, err
+	}
+	return entity.AccessKey{
+		AccessKey: strings.ToUpper(hex.EncodeToString(accessKey)),
+		SecretKey: base64.RawURLEncoding.EncodeToString(secretKey),
+	}, nil
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignCoversHostAndSignedHeaders(t *testing.T) {
+	newRequest := func(url, customerKey string) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		if customerKey != "" {
+			req.Header.Set("X-Customer-Key", customerKey)
+		}
+		return req
+	}
+
+	req := newRequest("https://front:8080/api/v1/getFile/id?a=1", "key")
+	SignRequest(req, "access", "secret", time.Unix(100, 0))
+	signature := req.Header.Get(SignatureHeader)
+	require.Equal(t, signature, Sign("secret", req, 100))
+	require.Equal(t, ContentSha256(nil), req.Header.Get(ContentSha256Header))
+
+	for _, other := range []*http.Request{
+		newRequest("https://other:8080/api/v1/getFile/id?a=1", "key"),
+		newRequest("https://front:8080/api/v1/getFile/id?a=1", ""),
+		newRequest("https://front:8080/api/v1/getFile/id?a=1", "other"),
+		newRequest("https://front:8080/api/v1/getFile/other?a=1", "key"),
+		newRequest("https://front:8080/api/v1/getFile/id?a=2", "key"),
+	} {
+		require.NotEqual(t, signature, Sign("secret", other, 100), other.URL.String())
+	}
+	require.NotEqual(t, signature, Sign("secret", req, 101))
+
+	req.Header.Set(ContentSha256Header, ContentSha256([]byte("body")))
+	require.NotEqual(t, signature, Sign("secret", req, 100))
+}
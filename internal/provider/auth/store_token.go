@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Operations of store server API, token of StoreOperationSetMode names mode instead of part.
+const (
+	StoreOperationUpload  = "upload"
+	StoreOperationGet     = "get"
+	StoreOperationStat    = "stat"
+	StoreOperationDelete  = "delete"
+	StoreOperationList    = "list"
+	StoreOperationStats   = "stats"
+	StoreOperationSetMode = "setMode"
+)
+
+const (
+	// storeTokenTTL is lifetime of store token, it is checked only when request starts, so long uploads are not affected.
+	storeTokenTTL = time.Minute
+	// storeDestructiveTokenTTL is lifetime of delete and setMode tokens, so captured one can be replayed only for seconds.
+	storeDestructiveTokenTTL = 5 * time.Second
+	// storeTokenClockSkew is allowed difference between clocks of front and store server.
+	storeTokenClockSkew = 5 * time.Second
+)
+
+var ErrInvalidStoreToken = errors.New("invalid store token")
+
+func storeTokenLifetime(operation string) time.Duration {
+	if operation == StoreOperationDelete || operation == StoreOperationSetMode {
+		return storeDestructiveTokenTTL
+	}
+	return storeTokenTTL
+}
+
+// StoreToken returns bearer token which allows operation on file part of store server audience until short expiry.
+func StoreToken(secret, audience, operation, fileName string, now time.Time) string {
+	expires := now.Add(storeTokenLifetime(operation))
+	payload := strings.Join([]string{audience, operation, fileName, strconv.FormatInt(expires.Unix(), 10)}, "\n")
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + tokenMAC(secret, payload)
+}
+
+// VerifyStoreToken checks that token is signed with secret for one of audiences, not expired and allows operation on file part.
+func VerifyStoreToken(secret, token string, audiences []string, operation, fileName string, now time.Time) error {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return fmt.Errorf("%w: malformed token", ErrInvalidStoreToken)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: malformed token", ErrInvalidStoreToken)
+	}
+	if !SignatureMatches(signature, tokenMAC(secret, string(payload))) {
+		return fmt.Errorf("%w: signature doesn't match", ErrInvalidStoreToken)
+	}
+
+	fields := strings.Split(string(payload), "\n")
+	if len(fields) != 4 {
+		return fmt.Errorf("%w: malformed token", ErrInvalidStoreToken)
+	}
+	if !slices.Contains(audiences, fields[0]) {
+		return fmt.Errorf("%w: token is issued for another store server %q", ErrInvalidStoreToken, fields[0])
+	}
+	if fields[1] != operation || fields[2] != fileName {
+		return fmt.Errorf("%w: token is issued for %s of %q", ErrInvalidStoreToken, fields[1], fields[2])
+	}
+	expires, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: malformed token", ErrInvalidStoreToken)
+	}
+	if now.Unix() > expires {
+		return fmt.Errorf("%w: token is expired", ErrInvalidStoreToken)
+	}
+	if time.Unix(expires, 0).Sub(now) > storeTokenLifetime(operation)+storeTokenClockSkew {
+		return fmt.Errorf("%w: token lifetime is too long", ErrInvalidStoreToken)
+	}
+	return nil
+}
+
+func tokenMAC(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
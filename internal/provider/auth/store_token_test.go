@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyStoreToken(t *testing.T) {
+	now := time.Now()
+	audiences := []string{"https://store0:9090", "grpc://store0:9190"}
+	token := StoreToken("secret", "grpc://store0:9190", StoreOperationGet, "part", now)
+	require.NoError(t, VerifyStoreToken("secret", token, audiences, StoreOperationGet, "part", now))
+
+	tests := []struct {
+		name      string
+		token     string
+		operation string
+		now       time.Time
+	}{
+		{name: "another store", token: StoreToken("secret", "https://store1:9090", StoreOperationGet, "part", now), operation: StoreOperationGet, now: now},
+		{name: "another secret", token: StoreToken("other", audiences[0], StoreOperationGet, "part", now), operation: StoreOperationGet, now: now},
+		{name: "another operation", token: token, operation: StoreOperationDelete, now: now},
+		{name: "expired", token: token, operation: StoreOperationGet, now: now.Add(2 * time.Minute)},
+		{name: "expired delete", token: StoreToken("secret", audiences[0], StoreOperationDelete, "part", now), operation: StoreOperationDelete, now: now.Add(10 * time.Second)},
+		{name: "delete with long lifetime", token: StoreToken("secret", audiences[0], StoreOperationGet, "part", now), operation: StoreOperationDelete, now: now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorIs(t, VerifyStoreToken("secret", tt.token, audiences, tt.operation, "part", tt.now), ErrInvalidStoreToken)
+		})
+	}
+}
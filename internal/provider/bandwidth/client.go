@@ -0,0 +1,86 @@
+package bandwidth
+
+import (
+	"context"
+	"io"
+
+	"github.com/itimofeev/yas3/internal/entity"
+)
+
+// Wrap returns store client which streams file content not faster than scheduler allows for priority of call context.
+func (s *Scheduler) Wrap(client entity.StoreClient) entity.StoreClient {
+	return &scheduledClient{client: client, scheduler: s}
+}
+
+type scheduledClient struct {
+	client    entity.StoreClient
+	scheduler *Scheduler
+}
+
+func (c *scheduledClient) GetID() string {
+	return c.client.GetID()
+}
+
+func (c *scheduledClient) UploadFile(ctx context.Context, fileName string, content io.Reader) error {
+	return c.client.UploadFile(ctx, fileName, c.reader(ctx, content))
+}
+
+func (c *scheduledClient) GetFile(ctx context.Context, fileName string) (io.ReadCloser, error) {
+	reader, err := c.client.GetFile(ctx, fileName)
+	if err != nil {
+		return nil, err
+	}
+	return &scheduledReadCloser{Reader: c.reader(ctx, reader), Closer: reader}, nil
+}
+
+func (c *scheduledClient) GetFileRange(ctx context.Context, fileName string, offset, length int64, ifRange string) (*entity.FileContent, error) {
+	content, err := c.client.GetFileRange(ctx, fileName, offset, length, ifRange)
+	if err != nil {
+		return nil, err
+	}
+	content.ReadCloser = &scheduledReadCloser{Reader: c.reader(ctx, content.ReadCloser), Closer: content.ReadCloser}
+	return content, nil
+}
+
+func (c *scheduledClient) DeleteFile(ctx context.Context, fileName string) error {
+	return c.client.DeleteFile(ctx, fileName)
+}
+
+func (c *scheduledClient) StatFile(ctx context.Context, fileName string) (entity.FileInfo, error) {
+	return c.client.StatFile(ctx, fileName)
+}
+
+func (c *scheduledClient) ListFiles(ctx context.Context, after string, limit int) ([]string, error) {
+	return c.client.ListFiles(ctx, after, limit)
+}
+
+func (c *scheduledClient) GetStats(ctx context.Context) (entity.StoreStats, error) {
+	return c.client.GetStats(ctx)
+}
+
+func (c *scheduledClient) reader(ctx context.Context, r io.Reader) io.Reader {
+	return &scheduledReader{ctx: ctx, r: r, storeID: c.client.GetID(), scheduler: c.scheduler}
+}
+
+// scheduledReader waits for bandwidth for every read chunk before it is passed further.
+type scheduledReader struct {
+	ctx       context.Context
+	r         io.Reader
+	storeID   string
+	scheduler *Scheduler
+}
+
+func (r *scheduledReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.scheduler.wait(r.ctx, r.storeID, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+type scheduledReadCloser struct {
+	io.Reader
+	io.Closer
+}
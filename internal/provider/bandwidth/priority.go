@@ -0,0 +1,32 @@
+package bandwidth
+
+import "context"
+
+type Priority int
+
+const (
+	// PriorityInteractive is traffic of user requests, it is priority of calls without priority in context.
+	PriorityInteractive Priority = iota
+	// PriorityBackground is traffic of background jobs like repair, it yields bandwidth to waiting interactive traffic.
+	PriorityBackground
+)
+
+func (p Priority) String() string {
+	if p == PriorityBackground {
+		return "background"
+	}
+	return "interactive"
+}
+
+type priorityCtxKey struct{}
+
+// WithPriority returns context of store client calls with traffic of priority.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityCtxKey{}, priority)
+}
+
+// PriorityFrom returns priority of traffic from context, interactive by default.
+func PriorityFrom(ctx context.Context) Priority {
+	priority, _ := ctx.Value(priorityCtxKey{}).(Priority)
+	return priority
+}
@@ -0,0 +1,194 @@
+package bandwidth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Config contains caps of traffic to store servers in bytes per second, zero value of cap means unlimited.
+type Config struct {
+	// TotalBytesPerSecond caps traffic to all store servers together.
+	TotalBytesPerSecond int64 `validate:"gte=0"`
+	// StoreBytesPerSecond caps traffic to every store server, StoreCaps override it for store servers by their ids.
+	StoreBytesPerSecond int64            `validate:"gte=0"`
+	StoreCaps           map[string]int64 `validate:"dive,gte=0"`
+	// BackgroundBytesPerSecond additionally caps background traffic to all store servers together.
+	BackgroundBytesPerSecond int64 `validate:"gte=0"`
+}
+
+// backgroundRetryInterval is how often background traffic checks if waiting interactive traffic got its bytes.
+const backgroundRetryInterval = 10 * time.Millisecond
+
+// Scheduler shares bandwidth to store servers between calls by total, per store and background token buckets.
+type Scheduler struct {
+	cfg Config
+
+	mu         sync.Mutex
+	total      *bucket
+	background *bucket
+	stores     map[string]*bucket
+
+	bytes  [2]atomic.Int64
+	waited [2]atomic.Int64
+}
+
+func New(cfg Config) (*Scheduler, error) {
+	err := validator.New().Struct(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("config validation error: %w", err)
+	}
+
+	now := time.Now()
+	return &Scheduler{
+		cfg:        cfg,
+		total:      newBucket(cfg.TotalBytesPerSecond, now),
+		background: newBucket(cfg.BackgroundBytesPerSecond, now),
+		stores:     make(map[string]*bucket),
+	}, nil
+}
+
+// bucket is token bucket with burst of one second of traffic, call can leave it in debt, so calls of any size are allowed.
+type bucket struct {
+	rate    float64
+	tokens  float64
+	last    time.Time
+	waiting [2]int
+}
+
+func newBucket(bytesPerSecond int64, now time.Time) *bucket {
+	return &bucket{rate: float64(bytesPerSecond), tokens: float64(bytesPerSecond), last: now}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if b.rate == 0 {
+		return
+	}
+	b.tokens = min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// delay returns time after which bucket has tokens again.
+func (b *bucket) delay() time.Duration {
+	if b.rate == 0 || b.tokens > 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens/b.rate*float64(time.Second)) + time.Millisecond
+}
+
+func (b *bucket) take(n int) {
+	if b.rate != 0 {
+		b.tokens -= float64(n)
+	}
+}
+
+// buckets returns buckets which limit traffic of priority to store server.
+func (s *Scheduler) buckets(storeID string, priority Priority) []*bucket {
+	store, ok := s.stores[storeID]
+	if !ok {
+		storeCap, ok := s.cfg.StoreCaps[storeID]
+		if !ok {
+			storeCap = s.cfg.StoreBytesPerSecond
+		}
+		store = newBucket(storeCap, time.Now())
+		s.stores[storeID] = store
+	}
+	if priority == PriorityBackground {
+		return []*bucket{s.total, store, s.background}
+	}
+	return []*bucket{s.total, store}
+}
+
+// wait blocks until n bytes of traffic to store server are allowed.
+func (s *Scheduler) wait(ctx context.Context, storeID string, n int) error {
+	priority := PriorityFrom(ctx)
+	s.bytes[priority].Add(int64(n))
+
+	s.mu.Lock()
+	buckets := s.buckets(storeID, priority)
+	if s.tryTake(buckets, priority, n) {
+		s.mu.Unlock()
+		return nil
+	}
+	for _, b := range buckets {
+		b.waiting[priority]++
+	}
+	s.mu.Unlock()
+
+	start := time.Now()
+	defer func() {
+		s.waited[priority].Add(int64(time.Since(start)))
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, b := range buckets {
+			b.waiting[priority]--
+		}
+	}()
+
+	for {
+		s.mu.Lock()
+		delay := s.delay(buckets, priority)
+		if delay == 0 {
+			for _, b := range buckets {
+				b.take(n)
+			}
+			s.mu.Unlock()
+			return nil
+		}
+		s.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// tryTake takes tokens without waiting if no call of the same or higher priority waits for them.
+func (s *Scheduler) tryTake(buckets []*bucket, priority Priority, n int) bool {
+	for _, b := range buckets {
+		if b.waiting[PriorityInteractive] > 0 || b.waiting[priority] > 0 {
+			return false
+		}
+	}
+	if s.delay(buckets, priority) > 0 {
+		return false
+	}
+	for _, b := range buckets {
+		b.take(n)
+	}
+	return true
+}
+
+// delay returns how long call has to wait before the next attempt to take tokens, zero means tokens can be taken now.
+func (s *Scheduler) delay(buckets []*bucket, priority Priority) time.Duration {
+	now := time.Now()
+	var delay time.Duration
+	for _, b := range buckets {
+		b.refill(now)
+		delay = max(delay, b.delay())
+		if priority == PriorityBackground && b.waiting[PriorityInteractive] > 0 {
+			delay = max(delay, backgroundRetryInterval)
+		}
+	}
+	return delay
+}
+
+// Metrics returns bytes and total time spent waiting for bandwidth by every priority.
+func (s *Scheduler) Metrics() any {
+	metrics := make(map[string]any)
+	for _, priority := range []Priority{PriorityInteractive, PriorityBackground} {
+		metrics[priority.String()] = map[string]any{
+			"bytes":  s.bytes[priority].Load(),
+			"waitMs": time.Duration(s.waited[priority].Load()).Milliseconds(),
+		}
+	}
+	return metrics
+}
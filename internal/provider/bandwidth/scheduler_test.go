@@ -0,0 +1,134 @@
+package bandwidth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRate  = 1_000_000
+	testChunk = 50_000
+)
+
+func newTestScheduler(t *testing.T, cfg Config) *Scheduler {
+	t.Helper()
+	s, err := New(cfg)
+	require.NoError(t, err)
+	return s
+}
+
+// transfer sends n bytes to store server in chunks.
+func transfer(t *testing.T, s *Scheduler, storeID string, n int) {
+	for sent := 0; sent < n; sent += testChunk {
+		if err := s.wait(context.Background(), storeID, testChunk); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// transferConcurrently sends n bytes to every store server at once and returns how long it took.
+func transferConcurrently(t *testing.T, s *Scheduler, n int, storeIDs ...string) time.Duration {
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, storeID := range storeIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			transfer(t, s, storeID, n)
+		}()
+	}
+	wg.Wait()
+	return time.Since(start)
+}
+
+// minTransferTime is the least time of sending n bytes through bucket with rate, burst and the last chunk don't wait.
+func minTransferTime(n, rate int) time.Duration {
+	return time.Duration(float64(n-rate-testChunk) / float64(rate) * float64(time.Second))
+}
+
+func TestSchedulerCaps(t *testing.T) {
+	t.Run("store cap", func(t *testing.T) {
+		s := newTestScheduler(t, Config{StoreBytesPerSecond: testRate})
+		elapsed := transferConcurrently(t, s, testRate*3/2, "store0", "store1")
+		require.GreaterOrEqual(t, elapsed, minTransferTime(testRate*3/2, testRate))
+	})
+	t.Run("store cap override", func(t *testing.T) {
+		s := newTestScheduler(t, Config{StoreBytesPerSecond: 10 * testRate, StoreCaps: map[string]int64{"store0": testRate}})
+		elapsed := transferConcurrently(t, s, testRate*3/2, "store0")
+		require.GreaterOrEqual(t, elapsed, minTransferTime(testRate*3/2, testRate))
+	})
+	t.Run("total cap", func(t *testing.T) {
+		s := newTestScheduler(t, Config{TotalBytesPerSecond: testRate, StoreBytesPerSecond: 10 * testRate})
+		elapsed := transferConcurrently(t, s, testRate*3/4, "store0", "store1")
+		require.GreaterOrEqual(t, elapsed, minTransferTime(testRate*3/2, testRate))
+	})
+	t.Run("unlimited", func(t *testing.T) {
+		s := newTestScheduler(t, Config{})
+		transferConcurrently(t, s, 10*testRate, "store0", "store1")
+		require.Zero(t, s.waited[PriorityInteractive].Load())
+	})
+}
+
+func TestSchedulerBackgroundYieldsToInteractive(t *testing.T) {
+	s := newTestScheduler(t, Config{StoreBytesPerSecond: testRate})
+	ctx := context.Background()
+	// bucket is left in debt of 100ms of traffic, so the next calls have to wait
+	require.NoError(t, s.wait(ctx, "store0", testRate+testRate/10))
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		finished []Priority
+	)
+	start := func(priority Priority) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.wait(WithPriority(ctx, priority), "store0", testRate/10); err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			finished = append(finished, priority)
+		}()
+	}
+
+	start(PriorityInteractive)
+	require.Eventually(t, func() bool { return s.waiting("store0") == [2]int{1, 0} }, time.Second, time.Millisecond)
+	start(PriorityBackground)
+	wg.Wait()
+
+	require.Equal(t, []Priority{PriorityInteractive, PriorityBackground}, finished)
+}
+
+func TestSchedulerCanceledWaitReleasesWaiting(t *testing.T) {
+	s := newTestScheduler(t, Config{TotalBytesPerSecond: testRate, StoreBytesPerSecond: testRate, BackgroundBytesPerSecond: testRate})
+	require.NoError(t, s.wait(context.Background(), "store0", 10*testRate))
+
+	for _, priority := range []Priority{PriorityInteractive, PriorityBackground} {
+		ctx, cancel := context.WithTimeout(WithPriority(context.Background(), priority), 20*time.Millisecond)
+		err := s.wait(ctx, "store0", 1)
+		cancel()
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range []*bucket{s.total, s.background, s.stores["store0"]} {
+		require.Equal(t, [2]int{}, b.waiting)
+	}
+}
+
+// waiting returns how many calls of every priority wait for bucket of store server.
+func (s *Scheduler) waiting(storeID string) [2]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if store, ok := s.stores[storeID]; ok {
+		return store.waiting
+	}
+	return [2]int{}
+}
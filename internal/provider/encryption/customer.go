@@ -0,0 +1,39 @@
+package encryption
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// CustomerKeyWrapper returns wrapper of data keys of file by key derived from customer key and file id.
+func CustomerKeyWrapper(customerKey []byte, fileID string) (*KeyWrapper, error) {
+	return newKeyWrapper(keyHMAC(customerKey, "wrapping key", fileID))
+}
+
+// CustomerKeyFingerprint identifies key provided by customer for the file, it can be stored instead of the key.
+func CustomerKeyFingerprint(customerKey []byte, fileID string) string {
+	return hex.EncodeToString(keyHMAC(customerKey, "fingerprint", fileID))
+}
+
+// ParseCustomerKey decodes base64 encoded customer key.
+func ParseCustomerKey(value string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != KeySize {
+		return nil, fmt.Errorf("customer key must be %d bytes encoded with base64", KeySize)
+	}
+	return key, nil
+}
+
+// FingerprintMatches compares fingerprints in constant time.
+func FingerprintMatches(a, b string) bool {
+	return hmac.Equal([]byte(a), []byte(b))
+}
+
+func keyHMAC(key []byte, purpose, fileID string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("yas3 " + purpose + " " + fileID))
+	return mac.Sum(nil)
+}
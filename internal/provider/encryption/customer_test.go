@@ -0,0 +1,43 @@
+package encryption
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomerKeyWrapper(t *testing.T) {
+	customerKey := bytes.Repeat([]byte{1}, KeySize)
+	wrapper, err := CustomerKeyWrapper(customerKey, "file")
+	require.NoError(t, err)
+
+	// every upload of the same file with the same customer key gets its own data key
+	key, wrapped, err := wrapper.NewDataKey()
+	require.NoError(t, err)
+	otherKey, otherWrapped, err := wrapper.NewDataKey()
+	require.NoError(t, err)
+	require.NotEqual(t, key, otherKey)
+	require.NotEqual(t, wrapped, otherWrapped)
+
+	sameWrapper, err := CustomerKeyWrapper(customerKey, "file")
+	require.NoError(t, err)
+	unwrapped, err := sameWrapper.UnwrapKey(wrapped)
+	require.NoError(t, err)
+	require.Equal(t, key, unwrapped)
+
+	for _, other := range []struct {
+		key    []byte
+		fileID string
+	}{
+		{key: bytes.Repeat([]byte{2}, KeySize), fileID: "file"},
+		{key: customerKey, fileID: "other"},
+	} {
+		otherWrapper, err := CustomerKeyWrapper(other.key, other.fileID)
+		require.NoError(t, err)
+		_, err = otherWrapper.UnwrapKey(wrapped)
+		require.Error(t, err)
+	}
+	require.Equal(t, CustomerKeyFingerprint(customerKey, "file"), CustomerKeyFingerprint(customerKey, "file"))
+	require.NotEqual(t, CustomerKeyFingerprint(customerKey, "file"), CustomerKeyFingerprint(customerKey, "other"))
+}
@@ -0,0 +1,98 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// KeySize is size of master key and data keys, AES-256 is used.
+const KeySize = 32
+
+type Config struct {
+	// KeyFilePath is path to file with master key, raw 32 bytes or 64 hex chars.
+	KeyFilePath string `validate:"required"`
+}
+
+// KeyWrapper generates data keys for files and wraps them with master key, so only wrapped keys are stored.
+type KeyWrapper struct {
+	master cipher.AEAD
+}
+
+func New(cfg Config) (*KeyWrapper, error) {
+	err := validator.New().Struct(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("config validation error: %w", err)
+	}
+
+	data, err := os.ReadFile(cfg.KeyFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key: %w", err)
+	}
+	masterKey, err := parseKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyWrapper(masterKey)
+}
+
+func newKeyWrapper(masterKey []byte) (*KeyWrapper, error) {
+	master, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyWrapper{master: master}, nil
+}
+
+func parseKey(data []byte) ([]byte, error) {
+	if len(data) == KeySize {
+		return data, nil
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != KeySize {
+		return nil, fmt.Errorf("master key must be %d raw bytes or %d hex chars", KeySize, KeySize*2)
+	}
+	return key, nil
+}
+
+// NewDataKey returns random data key and the same key wrapped with master key.
+func (w *KeyWrapper) NewDataKey() (key, wrapped []byte, err error) {
+	key = make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, w.master.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return key, w.master.Seal(nonce, nonce, key, nil), nil
+}
+
+// UnwrapKey returns data key wrapped by NewDataKey, it fails if key was wrapped with another master key.
+func (w *KeyWrapper) UnwrapKey(wrapped []byte) ([]byte, error) {
+	nonceSize := w.master.NonceSize()
+	if len(wrapped) < nonceSize {
+		return nil, errors.New("wrapped data key is too short")
+	}
+	key, err := w.master.Open(nil, wrapped[:nonceSize], wrapped[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
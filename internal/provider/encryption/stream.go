@@ -0,0 +1,201 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// SchemeChunkedAESGCM seals chunks of file part by AES-256-GCM with nonce of part number, chunk number and last chunk flag.
+	SchemeChunkedAESGCM = "aes-256-gcm-chunked-v1"
+	// DefaultChunkSize is size of plaintext in every chunk except the last one.
+	DefaultChunkSize = 64 * 1024
+)
+
+// ErrAuthentication is returned when encrypted chunk can't be decrypted: content is corrupted or key is wrong.
+var ErrAuthentication = errors.New("encrypted content authentication failed")
+
+// Cipher encrypts and decrypts parts of one file with its data key.
+type Cipher struct {
+	aead      cipher.AEAD
+	chunkSize int
+}
+
+func NewCipher(key []byte, chunkSize int) (*Cipher, error) {
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("invalid chunk size %d", chunkSize)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead, chunkSize: chunkSize}, nil
+}
+
+func (c *Cipher) nonce(partNumber int, chunk int64, last bool) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	// chunk number is written first, its highest byte is overwritten by part number
+	binary.BigEndian.PutUint64(nonce[3:11], uint64(chunk))
+	binary.BigEndian.PutUint32(nonce[0:4], uint32(partNumber))
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+func (c *Cipher) sealedChunkSize() int {
+	return c.chunkSize + c.aead.Overhead()
+}
+
+// firstChunk returns chunk to decrypt from to get plaintext from offset, offset on chunk boundary starts from the previous chunk.
+func (c *Cipher) firstChunk(offset int64) int64 {
+	if offset == 0 {
+		return 0
+	}
+	return (offset - 1) / int64(c.chunkSize)
+}
+
+// CiphertextOffset returns offset in encrypted part from which it has to be read to decrypt plaintext from offset.
+func (c *Cipher) CiphertextOffset(offset int64) int64 {
+	return c.firstChunk(offset) * int64(c.sealedChunkSize())
+}
+
+// EncryptReader returns encrypted content of file part.
+func (c *Cipher) EncryptReader(partNumber int, r io.Reader) io.Reader {
+	return &encryptReader{
+		c:          c,
+		src:        bufio.NewReader(r),
+		partNumber: partNumber,
+		buf:        make([]byte, c.chunkSize),
+		sealed:     make([]byte, 0, c.sealedChunkSize()),
+	}
+}
+
+type encryptReader struct {
+	c          *Cipher
+	src        *bufio.Reader
+	partNumber int
+	chunk      int64
+	buf        []byte
+	sealed     []byte
+	out        []byte
+	done       bool
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.sealNext(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *encryptReader) sealNext() error {
+	n, last, err := readChunk(r.src, r.buf)
+	if err != nil {
+		return err
+	}
+	r.out = r.c.aead.Seal(r.sealed[:0], r.c.nonce(r.partNumber, r.chunk, last), r.buf[:n], nil)
+	r.chunk++
+	r.done = last
+	return nil
+}
+
+// DecryptReader returns plaintext of file part from offset, r has to be encrypted part read from CiphertextOffset(offset).
+func (c *Cipher) DecryptReader(partNumber int, r io.Reader, offset int64) io.Reader {
+	chunk := c.firstChunk(offset)
+	return &decryptReader{
+		c:          c,
+		src:        bufio.NewReader(r),
+		partNumber: partNumber,
+		chunk:      chunk,
+		skip:       offset - chunk*int64(c.chunkSize),
+		buf:        make([]byte, c.sealedChunkSize()),
+		plain:      make([]byte, 0, c.chunkSize),
+	}
+}
+
+type decryptReader struct {
+	c          *Cipher
+	src        *bufio.Reader
+	partNumber int
+	chunk      int64
+	skip       int64
+	buf        []byte
+	plain      []byte
+	out        []byte
+	done       bool
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.openNext(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *decryptReader) openNext() error {
+	n, last, err := readChunk(r.src, r.buf)
+	if err != nil {
+		return err
+	}
+	if n < r.c.aead.Overhead() {
+		return io.ErrUnexpectedEOF
+	}
+	plain, err := r.c.aead.Open(r.plain[:0], r.c.nonce(r.partNumber, r.chunk, last), r.buf[:n], nil)
+	if err != nil && last && n == len(r.buf) {
+		// full chunk at the end of content is the last one, unless content is cut right after it
+		if _, openErr := r.c.aead.Open(r.plain[:0], r.c.nonce(r.partNumber, r.chunk, false), r.buf[:n], nil); openErr == nil {
+			return io.ErrUnexpectedEOF
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("chunk %d of part %d: %w", r.chunk, r.partNumber, ErrAuthentication)
+	}
+	if r.skip > int64(len(plain)) {
+		return io.ErrUnexpectedEOF
+	}
+	r.out = plain[r.skip:]
+	r.skip = 0
+	r.chunk++
+	r.done = last
+	return nil
+}
+
+// readChunk fills buf from r and reports if it is the last chunk, which is only when r ends with io.EOF.
+func readChunk(r *bufio.Reader, buf []byte) (n int, last bool, err error) {
+	for n < len(buf) {
+		var m int
+		m, err = r.Read(buf[n:])
+		n += m
+		if errors.Is(err, io.EOF) {
+			return n, true, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+	}
+	if _, err := r.Peek(1); errors.Is(err, io.EOF) {
+		return n, true, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	return n, false, nil
+}
@@ -0,0 +1,85 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testChunkSize = 16
+
+func newTestCipher(t *testing.T) *Cipher {
+	t.Helper()
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	c, err := NewCipher(key, testChunkSize)
+	require.NoError(t, err)
+	return c
+}
+
+func encrypt(t *testing.T, c *Cipher, plaintext []byte) []byte {
+	t.Helper()
+	sealed, err := io.ReadAll(c.EncryptReader(1, bytes.NewReader(plaintext)))
+	require.NoError(t, err)
+	return sealed
+}
+
+// cutReader returns content and then io.ErrUnexpectedEOF as HTTP body shorter than its Content-Length.
+type cutReader struct {
+	r io.Reader
+}
+
+func (c *cutReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func TestDecryptReader(t *testing.T) {
+	c := newTestCipher(t)
+	for _, size := range []int{0, 5, testChunkSize, 3*testChunkSize + 7, 4 * testChunkSize} {
+		plaintext := bytes.Repeat([]byte{'x'}, size)
+		sealed := encrypt(t, c, plaintext)
+
+		for _, offset := range []int{0, size / 2, size} {
+			content, err := io.ReadAll(c.DecryptReader(1, bytes.NewReader(sealed[c.CiphertextOffset(int64(offset)):]), int64(offset)))
+			require.NoError(t, err, "size %d offset %d", size, offset)
+			require.Equal(t, plaintext[offset:], content, "size %d offset %d", size, offset)
+		}
+	}
+}
+
+func TestDecryptReaderTruncated(t *testing.T) {
+	c := newTestCipher(t)
+	sealed := encrypt(t, c, bytes.Repeat([]byte{'x'}, 3*testChunkSize+7))
+	sealedChunk := c.sealedChunkSize()
+
+	t.Run("cut in the middle of chunk", func(t *testing.T) {
+		_, err := io.ReadAll(c.DecryptReader(1, &cutReader{r: bytes.NewReader(sealed[:sealedChunk+5])}, 0))
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		require.NotErrorIs(t, err, ErrAuthentication)
+	})
+	t.Run("cut on chunk boundary", func(t *testing.T) {
+		_, err := io.ReadAll(c.DecryptReader(1, bytes.NewReader(sealed[:2*sealedChunk]), 0))
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		require.NotErrorIs(t, err, ErrAuthentication)
+	})
+	t.Run("tampered chunk", func(t *testing.T) {
+		tampered := bytes.Clone(sealed)
+		tampered[sealedChunk+1] ^= 1
+		_, err := io.ReadAll(c.DecryptReader(1, bytes.NewReader(tampered), 0))
+		require.ErrorIs(t, err, ErrAuthentication)
+	})
+}
+
+func TestEncryptReaderCutSource(t *testing.T) {
+	c := newTestCipher(t)
+	_, err := io.ReadAll(c.EncryptReader(1, &cutReader{r: bytes.NewReader(make([]byte, 2*testChunkSize+3))}))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
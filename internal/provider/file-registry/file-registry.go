@@ -1,12 +1,16 @@
 package file_registry
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/dgraph-io/badger/v4"
 	"github.com/go-playground/validator/v10"
+
+	"github.com/itimofeev/yas3/internal/entity"
 )
 
 type Config struct {
@@ -34,15 +38,37 @@ func New(cfg Config) (*Registry, error) {
 	}, nil
 }
 
-func (r *Registry) SaveFileParts(fileID string, serverIDs []string) error {
+type fileRecord struct {
+	Parts      []partRecord      `json:"parts"`
+	Encryption *encryptionRecord `json:"encryption,omitempty"`
+}
+
+type encryptionRecord struct {
+	Scheme     string `json:"scheme"`
+	ChunkSize  int    `json:"chunkSize"`
+	WrappedKey []byte `json:"wrappedKey,omitempty"`
+	// KeyFingerprint is set for files encrypted with key provided by customer.
+	KeyFingerprint string `json:"keyFingerprint,omitempty"`
+}
+
+type partRecord struct {
+	ServerIDs []string `json:"serverIds"`
+}
+
+func (r *Registry) SaveFile(fileID string, meta entity.FileMeta) error {
+	value, err := encodeFile(meta)
+	if err != nil {
+		return err
+	}
+
 	return r.db.Update(func(txn *badger.Txn) error {
-		err := txn.Set([]byte(fileID), []byte(strings.Join(serverIDs, ",")))
+		err := txn.Set([]byte(fileID), value)
 		return err
 	})
 }
 
-func (r *Registry) GetFileParts(fileID string) ([]string, error) {
-	var serverIDs []string
+func (r *Registry) GetFile(fileID string) (entity.FileMeta, error) {
+	var meta entity.FileMeta
 	err := r.db.View(func(txn *badger.Txn) error {
 		item, err := txn.Get([]byte(fileID))
 		if err != nil {
@@ -53,11 +79,97 @@ func (r *Registry) GetFileParts(fileID string) ([]string, error) {
 		if err != nil {
 			return err
 		}
-		serverIDs = strings.Split(string(valueCopy), ",")
-		return nil
+		meta, err = decodeFile(valueCopy)
+		return err
 	})
 
-	return serverIDs, err
+	return meta, err
+}
+
+// UpdateFilePart replaces list of servers which keep replicas of file part.
+func (r *Registry) UpdateFilePart(fileID string, partNumber int, serverIDs []string) error {
+	return r.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(fileID))
+		if err != nil {
+			return err
+		}
+		valueCopy, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		meta, err := decodeFile(valueCopy)
+		if err != nil {
+			return err
+		}
+		if partNumber >= len(meta.Parts) {
+			return fmt.Errorf("file %s has no part %d", fileID, partNumber)
+		}
+
+		meta.Parts[partNumber].ServerIDs = serverIDs
+		value, err := encodeFile(meta)
+		if err != nil {
+			return err
+		}
+		return txn.Set([]byte(fileID), value)
+	})
+}
+
+// iteratePageSize is number of files read by one transaction of IterateFiles.
+const iteratePageSize = 1000
+
+// IterateFiles calls fn for every uploaded file until fn returns error, files are read by pages in short transactions.
+func (r *Registry) IterateFiles(fn func(fileID string, meta entity.FileMeta) error) error {
+	return r.iterateFiles(iteratePageSize, fn)
+}
+
+func (r *Registry) iterateFiles(pageSize int, fn func(fileID string, meta entity.FileMeta) error) error {
+	var after []byte
+	for {
+		ids, metas, err := r.filesPage(after, pageSize)
+		if err != nil {
+			return err
+		}
+		for i, fileID := range ids {
+			if err := fn(fileID, metas[i]); err != nil {
+				return err
+			}
+		}
+		if len(ids) < pageSize {
+			return nil
+		}
+		after = []byte(ids[len(ids)-1])
+	}
+}
+
+// filesPage returns up to limit files with ids greater than after.
+func (r *Registry) filesPage(after []byte, limit int) ([]string, []entity.FileMeta, error) {
+	var (
+		ids   []string
+		metas []entity.FileMeta
+	)
+	err := r.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(after); it.Valid() && len(ids) < limit; it.Next() {
+			item := it.Item()
+			if bytes.Equal(item.Key(), after) || bytes.HasPrefix(item.Key(), []byte(servicePrefix)) {
+				continue
+			}
+			valueCopy, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			meta, err := decodeFile(valueCopy)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, string(item.Key()))
+			metas = append(metas, meta)
+		}
+		return nil
+	})
+	return ids, metas, err
 }
 
 func (r *Registry) IsFileExists(fileID string) bool {
@@ -69,6 +181,187 @@ func (r *Registry) IsFileExists(fileID string) bool {
 	return !errors.Is(err, badger.ErrKeyNotFound)
 }
 
+// servicePrefix starts keys of records which are not files, file ids never start with it.
+const servicePrefix = "_"
+
+const accessKeyPrefix = servicePrefix + "auth/key/"
+
+type accessKeyRecord struct {
+	SecretKey string `json:"secretKey"`
+	Disabled  bool   `json:"disabled"`
+}
+
+// SaveAccessKeyWithPolicy creates or replaces access key of front API client together with its policy in one transaction.
+func (r *Registry) SaveAccessKeyWithPolicy(key entity.AccessKey, policy entity.Policy) error {
+	keyValue, err := json.Marshal(accessKeyRecord{SecretKey: key.SecretKey, Disabled: key.Disabled})
+	if err != nil {
+		return err
+	}
+	policyValue, err := json.Marshal(policyRecord(policy))
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Set([]byte(accessKeyPrefix+key.AccessKey), keyValue); err != nil {
+			return err
+		}
+		return txn.Set([]byte(policyPrefix+key.AccessKey), policyValue)
+	})
+}
+
+// SetAccessKeyDisabled disables or enables access key, entity.ErrAccessKeyNotFound is returned if there is no such key.
+func (r *Registry) SetAccessKeyDisabled(accessKey string, disabled bool) error {
+	return r.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(accessKeyPrefix + accessKey))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return entity.ErrAccessKeyNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var record accessKeyRecord
+		if err := item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &record)
+		}); err != nil {
+			return err
+		}
+
+		record.Disabled = disabled
+		value, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return txn.Set([]byte(accessKeyPrefix+accessKey), value)
+	})
+}
+
+// DeleteAccessKey deletes access key with its policy, it returns entity.ErrAccessKeyNotFound if there is no such key.
+func (r *Registry) DeleteAccessKey(accessKey string) error {
+	return r.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(accessKeyPrefix + accessKey)); errors.Is(err, badger.ErrKeyNotFound) {
+			return entity.ErrAccessKeyNotFound
+		} else if err != nil {
+			return err
+		}
+		if err := txn.Delete([]byte(accessKeyPrefix + accessKey)); err != nil {
+			return err
+		}
+		return txn.Delete([]byte(policyPrefix + accessKey))
+	})
+}
+
+// GetAccessKey returns entity.ErrAccessKeyNotFound if there is no such key.
+func (r *Registry) GetAccessKey(accessKey string) (entity.AccessKey, error) {
+	var record accessKeyRecord
+	err := r.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(accessKeyPrefix + accessKey))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return entity.ErrAccessKeyNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &record)
+		})
+	})
+	if err != nil {
+		return entity.AccessKey{}, err
+	}
+
+	return entity.AccessKey{AccessKey: accessKey, SecretKey: record.SecretKey, Disabled: record.Disabled}, nil
+}
+
+const policyPrefix = servicePrefix + "auth/policy/"
+
+type policyRecord struct {
+	Admin      bool     `json:"admin"`
+	ReadOnly   bool     `json:"readOnly"`
+	Operations []string `json:"operations"`
+	Prefixes   []string `json:"prefixes"`
+}
+
+// SavePolicy creates or replaces policy of access key.
+func (r *Registry) SavePolicy(accessKey string, policy entity.Policy) error {
+	value, err := json.Marshal(policyRecord(policy))
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(policyPrefix+accessKey), value)
+	})
+}
+
+// GetPolicy returns entity.ErrPolicyNotFound if access key has no policy.
+func (r *Registry) GetPolicy(accessKey string) (entity.Policy, error) {
+	var record policyRecord
+	err := r.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(policyPrefix + accessKey))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return entity.ErrPolicyNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &record)
+		})
+	})
+	if err != nil {
+		return entity.Policy{}, err
+	}
+
+	return entity.Policy(record), nil
+}
+
 func (r *Registry) Close() error {
 	return r.db.Close()
 }
+
+func encodeFile(meta entity.FileMeta) ([]byte, error) {
+	record := fileRecord{Parts: make([]partRecord, 0, len(meta.Parts))}
+	for _, part := range meta.Parts {
+		record.Parts = append(record.Parts, partRecord{ServerIDs: part.ServerIDs})
+	}
+	if enc := meta.Encryption; enc != nil {
+		record.Encryption = &encryptionRecord{
+			Scheme:         enc.Scheme,
+			ChunkSize:      enc.ChunkSize,
+			WrappedKey:     enc.WrappedKey,
+			KeyFingerprint: enc.KeyFingerprint,
+		}
+	}
+	return json.Marshal(record)
+}
+
+// decodeFile decodes json record, or comma separated list of servers with one server per part written by previous versions.
+func decodeFile(value []byte) (entity.FileMeta, error) {
+	if len(value) == 0 || value[0] != '{' {
+		serverIDs := strings.Split(string(value), ",")
+		meta := entity.FileMeta{Parts: make([]entity.FilePart, 0, len(serverIDs))}
+		for _, serverID := range serverIDs {
+			meta.Parts = append(meta.Parts, entity.FilePart{ServerIDs: []string{serverID}})
+		}
+		return meta, nil
+	}
+
+	var record fileRecord
+	if err := json.Unmarshal(value, &record); err != nil {
+		return entity.FileMeta{}, err
+	}
+	meta := entity.FileMeta{Parts: make([]entity.FilePart, 0, len(record.Parts))}
+	for _, part := range record.Parts {
+		meta.Parts = append(meta.Parts, entity.FilePart{ServerIDs: part.ServerIDs})
+	}
+	if enc := record.Encryption; enc != nil {
+		meta.Encryption = &entity.Encryption{
+			Scheme:         enc.Scheme,
+			ChunkSize:      enc.ChunkSize,
+			WrappedKey:     enc.WrappedKey,
+			KeyFingerprint: enc.KeyFingerprint,
+		}
+	}
+	return meta, nil
+}
@@ -0,0 +1,35 @@
+package file_registry
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/itimofeev/yas3/internal/entity"
+)
+
+func TestIterateFilesByPages(t *testing.T) {
+	r, err := New(Config{DBPath: t.TempDir()})
+	require.NoError(t, err)
+	defer r.Close()
+
+	var want []string
+	for i := range 7 {
+		fileID := "file" + strconv.Itoa(i)
+		require.NoError(t, r.SaveFile(fileID, entity.FileMeta{Parts: []entity.FilePart{{ServerIDs: []string{"store0"}}}}))
+		want = append(want, fileID)
+	}
+	require.NoError(t, r.SaveAccessKeyWithPolicy(entity.AccessKey{AccessKey: "key", SecretKey: "secret"}, entity.Policy{}))
+
+	for _, pageSize := range []int{1, 3, 7, 100} {
+		var got []string
+		err := r.iterateFiles(pageSize, func(fileID string, meta entity.FileMeta) error {
+			// registry is updated while iterating, as repair does
+			got = append(got, fileID)
+			return r.UpdateFilePart(fileID, 0, []string{"store1"})
+		})
+		require.NoError(t, err)
+		require.Equal(t, want, got, pageSize)
+	}
+}
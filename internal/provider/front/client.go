@@ -3,15 +3,22 @@ package front
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+
+	"github.com/itimofeev/yas3/internal/provider/auth"
 )
 
 type Config struct {
 	BasePath string `validate:"required"`
+	// AccessKey and SecretKey are used to sign requests, requests are not signed without them.
+	AccessKey string
+	SecretKey string `validate:"required_with=AccessKey"`
 }
 
 // Client for front rest server, can upload files and download already uploaded files by id
@@ -32,12 +39,23 @@ func New(cfg Config) (*Client, error) {
 	}, nil
 }
 
+// customerKeyHeader contains base64 encoded key provided by customer to encrypt file with.
+const customerKeyHeader = "X-Customer-Key"
+
 func (c *Client) UploadFile(ctx context.Context, fileName string, content []byte) error {
+	return c.UploadFileWithKey(ctx, fileName, content, nil)
+}
+
+// UploadFileWithKey uploads file encrypted with customer key, the same key has to be used to download it.
+func (c *Client) UploadFileWithKey(ctx context.Context, fileName string, content []byte, customerKey []byte) error {
 	url := fmt.Sprintf("%s/api/v1/uploadFile/%s?fileSize=%d", c.cfg.BasePath, fileName, len(content))
 	uploadReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(content))
 	if err != nil {
 		return err
 	}
+	setCustomerKey(uploadReq, customerKey)
+	uploadReq.Header.Set(auth.ContentSha256Header, auth.ContentSha256(content))
+	c.sign(uploadReq)
 	resp, err := c.httpClient.Do(uploadReq)
 	if err != nil {
 		return err
@@ -51,11 +69,18 @@ func (c *Client) UploadFile(ctx context.Context, fileName string, content []byte
 }
 
 func (c *Client) GetFile(ctx context.Context, fileName string) ([]byte, error) {
+	return c.GetFileWithKey(ctx, fileName, nil)
+}
+
+// GetFileWithKey downloads file encrypted with customer key.
+func (c *Client) GetFileWithKey(ctx context.Context, fileName string, customerKey []byte) ([]byte, error) {
 	url := c.cfg.BasePath + "/api/v1/getFile/" + fileName
 	uploadReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
+	setCustomerKey(uploadReq, customerKey)
+	c.sign(uploadReq)
 
 	resp, err := c.httpClient.Do(uploadReq)
 	if err != nil {
@@ -73,3 +98,15 @@ func (c *Client) GetFile(ctx context.Context, fileName string) ([]byte, error) {
 
 	return respBody, nil
 }
+
+func setCustomerKey(req *http.Request, customerKey []byte) {
+	if customerKey != nil {
+		req.Header.Set(customerKeyHeader, base64.StdEncoding.EncodeToString(customerKey))
+	}
+}
+
+func (c *Client) sign(req *http.Request) {
+	if c.cfg.AccessKey != "" {
+		auth.SignRequest(req, c.cfg.AccessKey, c.cfg.SecretKey, time.Now())
+	}
+}
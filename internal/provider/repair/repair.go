@@ -0,0 +1,284 @@
+package repair
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/itimofeev/yas3/internal/entity"
+	"github.com/itimofeev/yas3/internal/provider/bandwidth"
+)
+
+type serversRegistry interface {
+	GetStoreClients(serverIDs []string) ([]entity.StoreClient, error)
+	GetServerForReplica(fileID string, partNumber int64, existing []string) (entity.StoreClient, error)
+	GetOfflineServers(threshold time.Duration) []string
+	GetCorruptedFiles() map[string][]string
+}
+
+type fileRegistry interface {
+	IterateFiles(fn func(fileID string, meta entity.FileMeta) error) error
+	UpdateFilePart(fileID string, partNumber int, serverIDs []string) error
+}
+
+type Config struct {
+	// ScanInterval is pause between scans of file registry for parts to repair.
+	ScanInterval time.Duration `validate:"required"`
+	// OfflineThreshold is time after which replicas on offline store server are considered lost.
+	OfflineThreshold time.Duration `validate:"required"`
+	// Workers is number of parts repaired concurrently.
+	Workers         int             `validate:"required,gt=0"`
+	ServersRegistry serversRegistry `validate:"required"`
+	FileRegistry    fileRegistry    `validate:"required"`
+}
+
+// Repairer rebuilds replicas lost on store servers offline too long or reported as corrupted from healthy ones.
+type Repairer struct {
+	cfg   Config
+	queue chan task
+
+	muQueued sync.Mutex
+	queued   map[string]struct{}
+
+	scanned   atomic.Int64
+	repaired  atomic.Int64
+	failed    atomic.Int64
+	lastScan  atomic.Int64
+	inProcess atomic.Int64
+}
+
+// task is file part with replicas which must be rebuilt.
+type task struct {
+	fileID     string
+	partNumber int
+	serverIDs  []string
+	lost       []string
+}
+
+func (t task) fileName() string {
+	return entity.PartFileName(t.fileID, t.partNumber)
+}
+
+func New(cfg Config) (*Repairer, error) {
+	err := validator.New().Struct(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("config validation error: %w", err)
+	}
+
+	return &Repairer{
+		cfg:    cfg,
+		queue:  make(chan task, 1000),
+		queued: make(map[string]struct{}),
+	}, nil
+}
+
+// Run scans file registry and repairs parts with background priority until context is canceled.
+func (r *Repairer) Run(ctx context.Context) error {
+	ctx = bandwidth.WithPriority(ctx, bandwidth.PriorityBackground)
+	var wg sync.WaitGroup
+	for range r.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+	defer wg.Wait()
+
+	t := time.NewTimer(r.cfg.ScanInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := r.scan(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("repair scan failed", "err", err)
+			}
+			t.Reset(r.cfg.ScanInterval)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// scan puts parts with lost replicas to repair queue, it blocks while queue is full.
+func (r *Repairer) scan(ctx context.Context) error {
+	offline := r.cfg.ServersRegistry.GetOfflineServers(r.cfg.OfflineThreshold)
+	corrupted := r.cfg.ServersRegistry.GetCorruptedFiles()
+	if len(offline) == 0 && len(corrupted) == 0 {
+		return nil
+	}
+
+	var scanned int64
+	err := r.cfg.FileRegistry.IterateFiles(func(fileID string, meta entity.FileMeta) error {
+		for partNumber, part := range meta.Parts {
+			scanned++
+			t := task{fileID: fileID, partNumber: partNumber, serverIDs: part.ServerIDs}
+			for _, serverID := range part.ServerIDs {
+				if slices.Contains(offline, serverID) || slices.Contains(corrupted[serverID], t.fileName()) {
+					t.lost = append(t.lost, serverID)
+				}
+			}
+			if len(t.lost) == 0 || !r.markQueued(t.fileName()) {
+				continue
+			}
+
+			select {
+			case r.queue <- t:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+
+	r.scanned.Store(scanned)
+	r.lastScan.Store(time.Now().Unix())
+	return err
+}
+
+func (r *Repairer) markQueued(fileName string) bool {
+	r.muQueued.Lock()
+	defer r.muQueued.Unlock()
+
+	if _, ok := r.queued[fileName]; ok {
+		return false
+	}
+	r.queued[fileName] = struct{}{}
+	return true
+}
+
+func (r *Repairer) unmarkQueued(fileName string) {
+	r.muQueued.Lock()
+	defer r.muQueued.Unlock()
+
+	delete(r.queued, fileName)
+}
+
+func (r *Repairer) work(ctx context.Context) {
+	for {
+		select {
+		case t := <-r.queue:
+			r.inProcess.Add(1)
+			if err := r.repairPart(ctx, t); err != nil {
+				slog.Error("failed to repair file part", "fileName", t.fileName(), "lost", t.lost, "err", err)
+				r.failed.Add(1)
+			} else {
+				slog.Info("file part repaired", "fileName", t.fileName(), "lost", t.lost)
+				r.repaired.Add(1)
+			}
+			r.inProcess.Add(-1)
+			r.unmarkQueued(t.fileName())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// repairPart copies part from healthy replica to new servers, updates file registry and deletes corrupted replicas.
+func (r *Repairer) repairPart(ctx context.Context, t task) error {
+	healthyIDs := slices.DeleteFunc(slices.Clone(t.serverIDs), func(serverID string) bool {
+		return slices.Contains(t.lost, serverID)
+	})
+	if len(healthyIDs) == 0 {
+		return errors.New("no healthy replicas left")
+	}
+	healthy, err := r.cfg.ServersRegistry.GetStoreClients(healthyIDs)
+	if err != nil {
+		return err
+	}
+
+	serverIDs := slices.Clone(healthyIDs)
+	for range t.lost {
+		// lost servers are excluded too, so corrupted replica is not rewritten on the same server
+		target, err := r.cfg.ServersRegistry.GetServerForReplica(t.fileID, int64(t.partNumber), append(slices.Clone(serverIDs), t.lost...))
+		if err != nil {
+			return err
+		}
+		if err := copyReplica(ctx, t.fileName(), healthy, target); err != nil {
+			return err
+		}
+		serverIDs = append(serverIDs, target.GetID())
+	}
+
+	if err := r.cfg.FileRegistry.UpdateFilePart(t.fileID, t.partNumber, serverIDs); err != nil {
+		return err
+	}
+
+	if lost, err := r.cfg.ServersRegistry.GetStoreClients(t.lost); err == nil {
+		for _, storeClient := range lost {
+			if err := storeClient.DeleteFile(ctx, t.fileName()); err != nil {
+				slog.Warn("failed to delete corrupted replica", "fileName", t.fileName(), "serverId", storeClient.GetID(), "err", err)
+			}
+		}
+	}
+	return nil
+}
+
+// copyReplica copies file from the first healthy replica that works to target server and checks that checksums match.
+func copyReplica(ctx context.Context, fileName string, healthy []entity.StoreClient, target entity.StoreClient) error {
+	var errs []error
+	for _, source := range healthy {
+		err := func() error {
+			info, err := source.StatFile(ctx, fileName)
+			if err != nil {
+				return err
+			}
+
+			// target can already have the file if previous repair failed after copy
+			existing, err := target.StatFile(ctx, fileName)
+			switch {
+			case err == nil && existing.Checksum == info.Checksum:
+				return nil
+			case err == nil:
+				if err := target.DeleteFile(ctx, fileName); err != nil {
+					return err
+				}
+			case !errors.Is(err, entity.ErrFileNotFound):
+				return err
+			}
+
+			content, err := source.GetFile(ctx, fileName)
+			if err != nil {
+				return err
+			}
+			defer content.Close()
+
+			if err := target.UploadFile(ctx, fileName, content); err != nil {
+				return err
+			}
+			copied, err := target.StatFile(ctx, fileName)
+			if err != nil {
+				return err
+			}
+			if copied.Checksum != info.Checksum {
+				_ = target.DeleteFile(ctx, fileName)
+				return fmt.Errorf("checksum mismatch after copy from %s to %s", source.GetID(), target.GetID())
+			}
+			return nil
+		}()
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Metrics returns progress of repair, it is published by expvar.
+func (r *Repairer) Metrics() any {
+	return map[string]any{
+		"queueDepth":   len(r.queue),
+		"inProcess":    r.inProcess.Load(),
+		"repaired":     r.repaired.Load(),
+		"failed":       r.failed.Load(),
+		"partsScanned": r.scanned.Load(),
+		"lastScanUnix": r.lastScan.Load(),
+	}
+}
@@ -0,0 +1,213 @@
+package server_registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/itimofeev/yas3/internal/entity"
+)
+
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker stops requests to store server after consecutive errors and lets one trial request through after backoff.
+type circuitBreaker struct {
+	serverID   string
+	threshold  int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mu          sync.Mutex
+	state       breakerState
+	errorsCount int
+	backoff     time.Duration
+	openedAt    time.Time
+	trial       bool
+	transitions int64
+}
+
+func newCircuitBreaker(serverID string, threshold int, backoff time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		serverID:   serverID,
+		threshold:  threshold,
+		minBackoff: backoff,
+		maxBackoff: backoff * 32,
+		backoff:    backoff,
+	}
+}
+
+// allow returns error if request to store server must not be sent.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.backoff {
+			return fmt.Errorf("store server %s: %w", b.serverID, errCircuitOpen)
+		}
+		b.setState(breakerHalfOpen)
+		b.trial = true
+		return nil
+	case breakerHalfOpen:
+		if b.trial {
+			return fmt.Errorf("store server %s: %w", b.serverID, errCircuitOpen)
+		}
+		b.trial = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// done records result of request allowed by breaker. Errors caused by canceled request context are not counted.
+func (b *circuitBreaker) done(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	switch {
+	case err == nil:
+		b.errorsCount = 0
+		b.backoff = b.minBackoff
+		if b.state != breakerClosed {
+			b.setState(breakerClosed)
+		}
+	case ctx.Err() != nil:
+	case b.state == breakerHalfOpen:
+		b.backoff = min(b.backoff*2, b.maxBackoff)
+		b.open()
+	default:
+		b.errorsCount++
+		if b.state == breakerClosed && b.errorsCount >= b.threshold {
+			b.open()
+		}
+	}
+}
+
+func (b *circuitBreaker) open() {
+	b.openedAt = time.Now()
+	b.setState(breakerOpen)
+}
+
+func (b *circuitBreaker) setState(state breakerState) {
+	slog.Info("store server circuit breaker state changed", "id", b.serverID, "from", b.state, "to", state, "backoff", b.backoff)
+	b.state = state
+	b.transitions++
+}
+
+// isUnavailable returns true if server must not be chosen for new file parts because breaker rejects requests or waits for trial.
+func (b *circuitBreaker) isUnavailable() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state == breakerHalfOpen || b.state == breakerOpen && time.Since(b.openedAt) < b.backoff
+}
+
+func (b *circuitBreaker) metrics() (string, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state.String(), b.transitions
+}
+
+// storeFault returns error only for transport and server errors, rejected requests mean that store server works.
+func storeFault(err error) error {
+	var respErr *entity.StoreResponseError
+	if errors.Is(err, entity.ErrFileNotFound) || errors.Is(err, entity.ErrFileChanged) || errors.As(err, &respErr) && !respErr.ServerFault() {
+		return nil
+	}
+	return err
+}
+
+// breakerClient sends requests of real traffic to store server through circuit breaker.
+type breakerClient struct {
+	client  entity.StoreClient
+	breaker *circuitBreaker
+}
+
+func (c *breakerClient) GetID() string {
+	return c.client.GetID()
+}
+
+func (c *breakerClient) UploadFile(ctx context.Context, fileName string, content io.Reader) error {
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+	err := c.client.UploadFile(ctx, fileName, content)
+	c.breaker.done(ctx, storeFault(err))
+	return err
+}
+
+func (c *breakerClient) GetFile(ctx context.Context, fileName string) (io.ReadCloser, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	reader, err := c.client.GetFile(ctx, fileName)
+	c.breaker.done(ctx, storeFault(err))
+	return reader, err
+}
+
+func (c *breakerClient) GetFileRange(ctx context.Context, fileName string, offset, length int64, ifRange string) (*entity.FileContent, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	content, err := c.client.GetFileRange(ctx, fileName, offset, length, ifRange)
+	c.breaker.done(ctx, storeFault(err))
+	return content, err
+}
+
+func (c *breakerClient) DeleteFile(ctx context.Context, fileName string) error {
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+	err := c.client.DeleteFile(ctx, fileName)
+	c.breaker.done(ctx, storeFault(err))
+	return err
+}
+
+func (c *breakerClient) StatFile(ctx context.Context, fileName string) (entity.FileInfo, error) {
+	if err := c.breaker.allow(); err != nil {
+		return entity.FileInfo{}, err
+	}
+	info, err := c.client.StatFile(ctx, fileName)
+	c.breaker.done(ctx, storeFault(err))
+	return info, err
+}
+
+func (c *breakerClient) ListFiles(ctx context.Context, after string, limit int) ([]string, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	names, err := c.client.ListFiles(ctx, after, limit)
+	c.breaker.done(ctx, storeFault(err))
+	return names, err
+}
+
+// GetStats is used by health probes, so it is not limited by circuit breaker.
+func (c *breakerClient) GetStats(ctx context.Context) (entity.StoreStats, error) {
+	return c.client.GetStats(ctx)
+}
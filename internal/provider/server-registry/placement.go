@@ -0,0 +1,121 @@
+package server_registry
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/itimofeev/yas3/internal/entity"
+)
+
+const (
+	// PlacementLeastLoaded puts file parts on the least loaded store servers first.
+	PlacementLeastLoaded = "least-loaded"
+	// PlacementRendezvous puts file parts on store servers chosen by weighted rendezvous hashing of file id and part number.
+	PlacementRendezvous = "rendezvous"
+)
+
+// rankServers returns candidate servers ordered by preference to store given file part.
+func (r *Registry) rankServers(fileID string, partNumber int64, candidates []entity.StoreClient) []entity.StoreClient {
+	ranked := slices.Clone(candidates)
+	switch r.placementStrategy {
+	case PlacementRendezvous:
+		return rendezvousRank(fileID, partNumber, ranked, r.states)
+	default:
+		// rotate the least loaded servers so parts go to them one by one
+		shift := int(partNumber % int64(len(ranked)))
+		return append(ranked[shift:], ranked[:shift]...)
+	}
+}
+
+// rendezvousRank orders servers by weighted rendezvous score for given file part, weight of server is its configured capacity.
+func rendezvousRank(fileID string, partNumber int64, clients []entity.StoreClient, states map[string]StoreServerState) []entity.StoreClient {
+	scores := make(map[string]float64, len(clients))
+	for _, client := range clients {
+		scores[client.GetID()] = rendezvousScore(fileID, partNumber, client.GetID(), states[client.GetID()].Space.Capacity)
+	}
+	slices.SortFunc(clients, func(a, b entity.StoreClient) int {
+		if c := cmp.Compare(scores[b.GetID()], scores[a.GetID()]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.GetID(), b.GetID())
+	})
+	return clients
+}
+
+// rendezvousScore calculates -weight/ln(h) where h is a hash of file part and server id mapped to (0, 1).
+func rendezvousScore(fileID string, partNumber int64, serverID string, weight int64) float64 {
+	if weight <= 0 {
+		weight = 1
+	}
+	sum := sha256.Sum256([]byte(fileID + "." + strconv.FormatInt(partNumber, 10) + "@" + serverID))
+	h := (float64(binary.BigEndian.Uint64(sum[:8])>>11) + 0.5) / (1 << 53)
+	return -float64(weight) / math.Log(h)
+}
+
+// domainUsage counts how many parts of one file are already placed into every failure domain.
+type domainUsage struct {
+	zones   map[string]int
+	racks   map[string]int
+	hosts   map[string]int
+	servers map[string]int
+}
+
+func newDomainUsage() *domainUsage {
+	return &domainUsage{
+		zones:   make(map[string]int),
+		racks:   make(map[string]int),
+		hosts:   make(map[string]int),
+		servers: make(map[string]int),
+	}
+}
+
+func domainKeys(serverID string, labels entity.FailureDomain) (zone, rack, host, server string) {
+	zone = labels.Zone
+	rack = zone + "/" + labels.Rack
+	host = rack + "/" + labels.Host
+	return zone, rack, host, host + "/" + serverID
+}
+
+func (u *domainUsage) counts(serverID string, labels entity.FailureDomain) []int {
+	zone, rack, host, server := domainKeys(serverID, labels)
+	return []int{u.zones[zone], u.racks[rack], u.hosts[host], u.servers[server]}
+}
+
+func (u *domainUsage) add(serverID string, labels entity.FailureDomain) {
+	zone, rack, host, server := domainKeys(serverID, labels)
+	u.zones[zone]++
+	u.racks[rack]++
+	u.hosts[host]++
+	u.servers[server]++
+}
+
+// pickSpread chooses the most preferred server not from exclude in the least used failure domain, nil if there is none.
+func pickSpread(ranked []entity.StoreClient, states map[string]StoreServerState, exclude []string, usages ...*domainUsage) entity.StoreClient {
+	var (
+		best       entity.StoreClient
+		bestCounts []int
+	)
+	for _, client := range ranked {
+		if slices.Contains(exclude, client.GetID()) {
+			continue
+		}
+		counts := make([]int, 0, 4*len(usages))
+		for _, usage := range usages {
+			counts = append(counts, usage.counts(client.GetID(), states[client.GetID()].Labels)...)
+		}
+		if best == nil || slices.Compare(counts, bestCounts) < 0 {
+			best, bestCounts = client, counts
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	for _, usage := range usages {
+		usage.add(best.GetID(), states[best.GetID()].Labels)
+	}
+	return best
+}
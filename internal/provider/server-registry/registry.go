@@ -2,6 +2,7 @@ package server_registry
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -12,19 +13,41 @@ import (
 	"github.com/go-playground/validator/v10"
 
 	"github.com/itimofeev/yas3/internal/entity"
+	"github.com/itimofeev/yas3/internal/provider/bandwidth"
 	"github.com/itimofeev/yas3/internal/provider/store"
 )
 
 type Config struct {
 	StoreServerAddrs []string `validate:"required"`
+	// StoreTLSConfig is used to connect to store servers.
+	StoreTLSConfig *tls.Config `validate:"required"`
+	// StoreTokenSecret signs tokens of requests to store servers.
+	StoreTokenSecret string
+	// Bandwidth schedules traffic of every store client, traffic is not limited when it is nil.
+	Bandwidth *bandwidth.Scheduler
+	// PlacementStrategy is the way to choose store servers for file parts, PlacementLeastLoaded by default.
+	PlacementStrategy string `validate:"omitempty,oneof=least-loaded rendezvous"`
+
+	// ProbeInterval is how often store servers are asked for their state.
+	ProbeInterval time.Duration `validate:"required"`
+	// ProbeTimeout limits every single state request, so hung store server doesn't delay others.
+	ProbeTimeout time.Duration `validate:"required"`
+	// BreakerErrorsThreshold is number of consecutive errors on real traffic after which store server circuit breaker opens.
+	BreakerErrorsThreshold int `validate:"required,gt=0"`
+	// BreakerBackoff is initial time after which open circuit breaker lets trial request through.
+	BreakerBackoff time.Duration `validate:"required"`
 }
 
 // Registry stores information about store servers. Periodically checks store servers available space in order to use the least loaded servers first.
 type Registry struct {
-	storeClients map[string]entity.StoreClient
+	cfg               Config
+	storeClients      map[string]entity.StoreClient
+	breakers          map[string]*circuitBreaker
+	placementStrategy string
 
 	mostFreeClients []entity.StoreClient
 	states          map[string]StoreServerState
+	transitions     map[string]int64
 	muState         sync.RWMutex
 }
 
@@ -35,99 +58,215 @@ func New(ctx context.Context, cfg Config) (*Registry, error) {
 	}
 
 	storeClients := make(map[string]entity.StoreClient)
+	breakers := make(map[string]*circuitBreaker)
 	for _, storeAddr := range cfg.StoreServerAddrs {
-		client, err := store.New(store.Config{
-			StoreAddr: storeAddr,
+		client, err := store.NewClient(store.Config{
+			StoreAddr:   storeAddr,
+			TLSConfig:   cfg.StoreTLSConfig,
+			TokenSecret: cfg.StoreTokenSecret,
 		})
 		if err != nil {
 			return nil, err
 		}
-		storeClients[client.GetID()] = client
+		if cfg.Bandwidth != nil {
+			client = cfg.Bandwidth.Wrap(client)
+		}
+		breaker := newCircuitBreaker(client.GetID(), cfg.BreakerErrorsThreshold, cfg.BreakerBackoff)
+		breakers[client.GetID()] = breaker
+		storeClients[client.GetID()] = &breakerClient{client: client, breaker: breaker}
+	}
+
+	placementStrategy := cfg.PlacementStrategy
+	if placementStrategy == "" {
+		placementStrategy = PlacementLeastLoaded
 	}
 
 	r := &Registry{
-		storeClients: storeClients,
+		cfg:               cfg,
+		storeClients:      storeClients,
+		breakers:          breakers,
+		placementStrategy: placementStrategy,
+		transitions:       make(map[string]int64),
 	}
 	r.updateStates(ctx)
 	return r, nil
 }
 
-// GetServersForParts returns the least loaded servers to store file parts. Uses server states to decide which servers are more free.
-func (r *Registry) GetServersForParts(nFileParts int64) ([]entity.StoreClient, error) {
+// GetServersForParts returns servers for replicas of every file part spread across failure domains, file registry is the only source of part locations.
+func (r *Registry) GetServersForParts(fileID string, nFileParts int64, replicas int) ([][]entity.StoreClient, error) {
 	r.muState.RLock()
 	defer r.muState.RUnlock()
 
-	if len(r.mostFreeClients) == 0 {
-		return nil, errors.New("all stores are offline")
+	candidates := r.availableClients()
+	if len(candidates) == 0 {
+		return nil, errors.New("all stores are offline, read-only or failing")
+	}
+	if len(candidates) < replicas {
+		return nil, fmt.Errorf("not enough available stores for %d replicas: %d", replicas, len(candidates))
 	}
 
-	storeClients := make([]entity.StoreClient, 0, nFileParts)
+	fileUsage := newDomainUsage()
+	storeClients := make([][]entity.StoreClient, 0, nFileParts)
 	for n := range nFileParts {
-		storeClients = append(storeClients, r.mostFreeClients[n%int64(len(r.mostFreeClients))])
+		ranked := r.rankServers(fileID, n, candidates)
+		partUsage := newDomainUsage()
+		partClients := make([]entity.StoreClient, 0, replicas)
+		partServerIDs := make([]string, 0, replicas)
+		for range replicas {
+			client := pickSpread(ranked, r.states, partServerIDs, partUsage, fileUsage)
+			partClients = append(partClients, client)
+			partServerIDs = append(partServerIDs, client.GetID())
+		}
+		storeClients = append(storeClients, partClients)
 	}
 	return storeClients, nil
 }
 
-// GetStoreClients returns list of clients to store servers that can be used to download files from.
+// GetServerForReplica returns server for one more replica of file part, preferring failure domain without existing replicas.
+func (r *Registry) GetServerForReplica(fileID string, partNumber int64, existing []string) (entity.StoreClient, error) {
+	r.muState.RLock()
+	defer r.muState.RUnlock()
+
+	partUsage := newDomainUsage()
+	for _, serverID := range existing {
+		partUsage.add(serverID, r.states[serverID].Labels)
+	}
+
+	client := pickSpread(r.rankServers(fileID, partNumber, r.availableClients()), r.states, existing, partUsage)
+	if client == nil {
+		return nil, fmt.Errorf("no available store for replica of part %d of file %s", partNumber, fileID)
+	}
+	return client, nil
+}
+
+// availableClients returns servers that can be used for new file parts.
+func (r *Registry) availableClients() []entity.StoreClient {
+	candidates := make([]entity.StoreClient, 0, len(r.mostFreeClients))
+	for _, client := range r.mostFreeClients {
+		if !r.breakers[client.GetID()].isUnavailable() {
+			candidates = append(candidates, client)
+		}
+	}
+	return candidates
+}
+
+// GetStoreClients returns list of clients to online store servers from given ones, that can be used to download files from.
 func (r *Registry) GetStoreClients(serverIDs []string) ([]entity.StoreClient, error) {
 	r.muState.RLock()
 	defer r.muState.RUnlock()
 
 	clients := make([]entity.StoreClient, 0, len(serverIDs))
 	for _, serverID := range serverIDs {
-		state := r.states[serverID]
-		if !state.IsOnline {
-			return nil, fmt.Errorf("storeClient for server %s is offline", serverID)
+		if state := r.states[serverID]; state.IsOnline {
+			clients = append(clients, r.storeClients[serverID])
 		}
-		clients = append(clients, r.storeClients[serverID])
+	}
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("all store servers %v are offline", serverIDs)
 	}
 	return clients, nil
 }
 
+// GetOfflineServers returns servers which are offline longer than threshold.
+func (r *Registry) GetOfflineServers(threshold time.Duration) []string {
+	r.muState.RLock()
+	defer r.muState.RUnlock()
+
+	var offline []string
+	for id, state := range r.states {
+		if !state.IsOnline && time.Since(state.OfflineSince) > threshold {
+			offline = append(offline, id)
+		}
+	}
+	return offline
+}
+
 // Run periodically asks store servers about their space statistics.
 func (r *Registry) Run(ctx context.Context) error {
-	t := time.NewTimer(time.Second * 10)
+	t := time.NewTimer(r.cfg.ProbeInterval)
 	for {
 		select {
 		case <-t.C:
 			r.updateStates(ctx)
-			t.Reset(time.Second * 10)
+			t.Reset(r.cfg.ProbeInterval)
 		case <-ctx.Done():
 			return nil
 		}
 	}
 }
 
+// receiveNewStates asks all store servers concurrently, every request is limited by probe timeout.
 func (r *Registry) receiveNewStates(ctx context.Context) map[string]StoreServerState {
 	states := make(map[string]StoreServerState)
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
 	for _, client := range r.storeClients {
-		space, err := client.GetAvailableSpace(ctx)
-		if err != nil {
-			slog.Warn("store client returned error", "id", client.GetID(), "err", err)
-			states[client.GetID()] = StoreServerState{
-				ID:       client.GetID(),
-				IsOnline: false,
-			}
-			continue
-		}
-		states[client.GetID()] = StoreServerState{
-			ID:       client.GetID(),
-			Space:    space,
-			IsOnline: true,
-		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			state := r.probe(ctx, client)
+
+			mu.Lock()
+			defer mu.Unlock()
+			states[state.ID] = state
+		}()
 	}
+	wg.Wait()
 	return states
 }
 
+func (r *Registry) probe(ctx context.Context, client entity.StoreClient) StoreServerState {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.ProbeTimeout)
+	defer cancel()
+
+	stats, err := client.GetStats(ctx)
+	if err != nil {
+		slog.Warn("store client returned error", "id", client.GetID(), "err", err)
+		return StoreServerState{
+			ID:           client.GetID(),
+			IsOnline:     false,
+			OfflineSince: time.Now(),
+		}
+	}
+	return StoreServerState{
+		ID:       client.GetID(),
+		Space:    stats.Space,
+		Labels:   stats.Labels,
+		ReadOnly: stats.ReadOnly,
+		IsOnline: true,
+
+		CorruptedFiles: stats.CorruptedFiles,
+	}
+}
+
 func (r *Registry) updateStates(ctx context.Context) {
 	newStates := r.receiveNewStates(ctx)
 
 	r.muState.Lock()
 	defer r.muState.Unlock()
 
+	for id, state := range newStates {
+		prev, ok := r.states[id]
+		if ok && prev.IsOnline != state.IsOnline {
+			slog.Info("store server state changed", "id", id, "isOnline", state.IsOnline)
+			r.transitions[id]++
+		}
+		if !state.IsOnline && ok && !prev.IsOnline {
+			state.OfflineSince = prev.OfflineSince
+			newStates[id] = state
+		}
+		for _, fileName := range state.CorruptedFiles {
+			if !slices.Contains(prev.CorruptedFiles, fileName) {
+				slog.Warn("store server reported corrupted file", "id", id, "fileName", fileName)
+			}
+		}
+	}
+
 	newFreeClients := make([]entity.StoreClient, 0, len(r.storeClients))
 	for _, state := range newStates {
-		if state.IsOnline {
+		if state.IsOnline && !state.ReadOnly {
 			newFreeClients = append(newFreeClients, r.storeClients[state.ID])
 		}
 	}
@@ -140,10 +279,50 @@ func (r *Registry) updateStates(ctx context.Context) {
 	slog.Debug("new store server states received", "states", newStates, "serversOnline", len(newFreeClients))
 }
 
+// GetCorruptedFiles returns files with corrupted content reported by every store server.
+func (r *Registry) GetCorruptedFiles() map[string][]string {
+	r.muState.RLock()
+	defer r.muState.RUnlock()
+
+	corrupted := make(map[string][]string)
+	for id, state := range r.states {
+		if len(state.CorruptedFiles) > 0 {
+			corrupted[id] = state.CorruptedFiles
+		}
+	}
+	return corrupted
+}
+
+// Metrics returns current state of every store server, it is published by expvar.
+func (r *Registry) Metrics() any {
+	r.muState.RLock()
+	defer r.muState.RUnlock()
+
+	metrics := make(map[string]any, len(r.storeClients))
+	for id, state := range r.states {
+		breakerState, breakerTransitions := r.breakers[id].metrics()
+		metrics[id] = map[string]any{
+			"isOnline":           state.IsOnline,
+			"readOnly":           state.ReadOnly,
+			"corruptedFiles":     len(state.CorruptedFiles),
+			"stateTransitions":   r.transitions[id],
+			"breaker":            breakerState,
+			"breakerTransitions": breakerTransitions,
+		}
+	}
+	return metrics
+}
+
 type StoreServerState struct {
 	ID       string
 	Space    entity.AvailableSpace
+	Labels   entity.FailureDomain
+	ReadOnly bool
 	IsOnline bool
+	// OfflineSince is time of the first failed probe in a row.
+	OfflineSince time.Time
+
+	CorruptedFiles []string
 }
 
 func (s StoreServerState) GetAvailableSpacePercent() int {
@@ -3,21 +3,29 @@ package store
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
-	"encoding/pem"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/quic-go/quic-go/http3"
 
 	"github.com/itimofeev/yas3/internal/entity"
+	"github.com/itimofeev/yas3/internal/provider/auth"
 )
 
 type Config struct {
 	StoreAddr string `validate:"required"`
+	// TLSConfig verifies certificate of store server and contains client certificate if store server requires it.
+	TLSConfig *tls.Config `validate:"required"`
+	// TokenSecret is shared with store server and signs token of every request for StoreAddr, requests are not signed when it is empty.
+	TokenSecret string
 }
 
 // Client for store server. Can upload, download files and query for server state.
@@ -27,15 +35,21 @@ type Client struct {
 }
 
 func New(cfg Config) (*Client, error) {
-	roundTripper := &http3.RoundTripper{
-		TLSClientConfig: &tls.Config{
-			RootCAs:            getRootCA(),
-			InsecureSkipVerify: true,
-		},
+	err := validator.New().Struct(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("config validation error: %w", err)
+	}
+
+	quicTransport := &http3.RoundTripper{
+		TLSClientConfig: cfg.TLSConfig,
+	}
+	tcpTransport := &http.Transport{
+		TLSClientConfig:   cfg.TLSConfig.Clone(),
+		ForceAttemptHTTP2: true,
 	}
 
 	httpClient := http.Client{
-		Transport: roundTripper,
+		Transport: newFallbackTransport(cfg.StoreAddr, quicTransport, tcpTransport),
 	}
 
 	return &Client{
@@ -51,13 +65,14 @@ func (c *Client) UploadFile(ctx context.Context, fileName string, content io.Rea
 	if err != nil {
 		return err
 	}
+	c.setToken(uploadReq, auth.StoreOperationUpload, fileName)
 	resp, err := c.httpClient.Do(uploadReq)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("response code not 200: %d", resp.StatusCode)
+		return responseError(resp.StatusCode)
 	}
 
 	slog.Debug("file uploaded to store server", "fileName", fileName, "serverId", c.GetID())
@@ -65,88 +80,219 @@ func (c *Client) UploadFile(ctx context.Context, fileName string, content io.Rea
 }
 
 func (c *Client) GetFile(ctx context.Context, fileName string) (io.ReadCloser, error) {
+	content, err := c.GetFileRange(ctx, fileName, 0, -1, "")
+	if err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+func (c *Client) GetFileRange(ctx context.Context, fileName string, offset, length int64, ifRange string) (*entity.FileContent, error) {
 	url := c.cfg.StoreAddr + "/api/v1/getFile/" + fileName
-	uploadReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	getReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := c.httpClient.Do(uploadReq)
+	c.setToken(getReq, auth.StoreOperationGet, fileName)
+
+	ranged := offset > 0 || length >= 0
+	if ranged {
+		rangeHeader := "bytes=" + strconv.FormatInt(offset, 10) + "-"
+		if length >= 0 {
+			rangeHeader += strconv.FormatInt(offset+length-1, 10)
+		}
+		getReq.Header.Set("Range", rangeHeader)
+	}
+	if ifRange != "" {
+		getReq.Header.Set("If-Range", ifRange)
+	}
+
+	resp, err := c.httpClient.Do(getReq)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
+	switch {
+	case resp.StatusCode == http.StatusOK && !ranged:
+		return &entity.FileContent{ReadCloser: resp.Body, Size: resp.ContentLength, ETag: resp.Header.Get("Etag")}, nil
+	case resp.StatusCode == http.StatusPartialContent && ranged:
+		return &entity.FileContent{ReadCloser: resp.Body, Size: contentRangeSize(resp.Header.Get("Content-Range")), ETag: resp.Header.Get("Etag")}, nil
+	case resp.StatusCode == http.StatusOK && ifRange != "":
+		// store server ignores range when If-Range doesn't match and returns the whole file
 		_ = resp.Body.Close()
-		return nil, err
+		return nil, entity.ErrFileChanged
 	}
 
-	return resp.Body, nil
+	_ = resp.Body.Close()
+	return nil, responseError(resp.StatusCode)
 }
 
-func (c *Client) GetAvailableSpace(ctx context.Context) (entity.AvailableSpace, error) {
-	url := c.cfg.StoreAddr + "/api/v1/getAvailableSpace"
-	uploadReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// contentRangeSize returns size of the whole file from Content-Range header like "bytes 0-99/100", -1 if it is unknown.
+func contentRangeSize(contentRange string) int64 {
+	_, sizeStr, ok := strings.Cut(contentRange, "/")
+	if !ok {
+		return -1
+	}
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
 	if err != nil {
-		return entity.AvailableSpace{}, err
+		return -1
 	}
-	resp, err := c.httpClient.Do(uploadReq)
+	return size
+}
+
+func (c *Client) DeleteFile(ctx context.Context, fileName string) error {
+	url := c.cfg.StoreAddr + "/api/v1/parts/" + fileName
+	deleteReq, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+	c.setToken(deleteReq, auth.StoreOperationDelete, fileName)
+	resp, err := c.httpClient.Do(deleteReq)
 	if err != nil {
-		return entity.AvailableSpace{}, err
+		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return entity.AvailableSpace{}, fmt.Errorf("response code not 200: %d", resp.StatusCode)
+		return responseError(resp.StatusCode)
 	}
 
-	m := make(map[string]int64)
-	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
-		return entity.AvailableSpace{}, err
+	return nil
+}
+
+func (c *Client) StatFile(ctx context.Context, fileName string) (entity.FileInfo, error) {
+	url := c.cfg.StoreAddr + "/api/v1/parts/" + fileName
+	headReq, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return entity.FileInfo{}, err
+	}
+	c.setToken(headReq, auth.StoreOperationStat, fileName)
+	resp, err := c.httpClient.Do(headReq)
+	if err != nil {
+		return entity.FileInfo{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return entity.FileInfo{}, responseError(resp.StatusCode)
 	}
 
-	return entity.AvailableSpace{
-		Total: m["total"],
-		Used:  m["used"],
+	modTime, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		return entity.FileInfo{}, err
+	}
+
+	return entity.FileInfo{
+		Size:     resp.ContentLength,
+		ModTime:  modTime,
+		Checksum: resp.Header.Get(checksumHeader),
 	}, nil
 }
 
-func (c *Client) GetID() string {
-	return c.cfg.StoreAddr
-}
+func (c *Client) ListFiles(ctx context.Context, after string, limit int) ([]string, error) {
+	query := url.Values{}
+	query.Set("after", after)
+	query.Set("limit", strconv.Itoa(limit))
+	listURL := c.cfg.StoreAddr + "/api/v1/parts?" + query.Encode()
+	listReq, err := http.NewRequestWithContext(ctx, http.MethodGet, listURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setToken(listReq, auth.StoreOperationList, "")
+	resp, err := c.httpClient.Do(listReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp.StatusCode)
+	}
 
-func getRootCA() *x509.CertPool {
-	p, _ := pem.Decode([]byte(rootCA))
-	if p.Type != "CERTIFICATE" {
-		panic("expected a certificate")
+	var list struct {
+		Names []string `json:"names"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
 	}
 
-	caCert, err := x509.ParseCertificate(p.Bytes)
+	return list.Names, nil
+}
+
+type statsResponse struct {
+	Total       int64 `json:"total"`
+	Capacity    int64 `json:"capacity"`
+	Used        int64 `json:"used"`
+	LogicalUsed int64 `json:"logicalUsed"`
+	FilesCount  int64 `json:"filesCount"`
+	InodesTotal int64 `json:"inodesTotal"`
+	InodesFree  int64 `json:"inodesFree"`
+	ReadOnly    bool  `json:"readOnly"`
+	// CorruptedFiles is the list of files with content that doesn't match checksum.
+	CorruptedFiles []string `json:"corruptedFiles"`
+	Labels         struct {
+		Zone string `json:"zone"`
+		Rack string `json:"rack"`
+		Host string `json:"host"`
+	} `json:"labels"`
+}
+
+func (c *Client) GetStats(ctx context.Context) (entity.StoreStats, error) {
+	url := c.cfg.StoreAddr + "/api/v1/getStats"
+	statsReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return entity.StoreStats{}, err
+	}
+	c.setToken(statsReq, auth.StoreOperationStats, "")
+	resp, err := c.httpClient.Do(statsReq)
 	if err != nil {
-		panic(err)
+		return entity.StoreStats{}, err
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return entity.StoreStats{}, responseError(resp.StatusCode)
+	}
+
+	var stats statsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return entity.StoreStats{}, err
+	}
+
+	return entity.StoreStats{
+		Space: entity.AvailableSpace{
+			Total:    stats.Total,
+			Used:     stats.Used,
+			Capacity: stats.Capacity,
+
+			LogicalUsed: stats.LogicalUsed,
+		},
+		FilesCount:  stats.FilesCount,
+		InodesTotal: stats.InodesTotal,
+		InodesFree:  stats.InodesFree,
+		Labels: entity.FailureDomain{
+			Zone: stats.Labels.Zone,
+			Rack: stats.Labels.Rack,
+			Host: stats.Labels.Host,
+		},
+		ReadOnly:       stats.ReadOnly,
+		CorruptedFiles: stats.CorruptedFiles,
+	}, nil
+}
 
-	certPool := x509.NewCertPool()
-	certPool.AddCert(caCert)
+func (c *Client) GetID() string {
+	return c.cfg.StoreAddr
+}
 
-	return certPool
+// checksumHeader is the same header as store server uses to return file checksum.
+const checksumHeader = "X-Checksum-Sha256"
+
+func responseError(statusCode int) error {
+	if statusCode == http.StatusNotFound {
+		return entity.ErrFileNotFound
+	}
+	return &entity.StoreResponseError{StatusCode: statusCode}
 }
 
-const rootCA = `-----BEGIN CERTIFICATE-----
-MIIDMTCCAhmgAwIBAgIUEHLr7ydHz4+E2R79VRGGvV8EcMEwDQYJKoZIhvcNAQEL
-BQAwKDEmMCQGA1UECgwdcXVpYy1nbyBDZXJ0aWZpY2F0ZSBBdXRob3JpdHkwHhcN
-MjQwOTIwMDgyNjA1WhcNMzQwOTE4MDgyNjA1WjAoMSYwJAYDVQQKDB1xdWljLWdv
-IENlcnRpZmljYXRlIEF1dGhvcml0eTCCASIwDQYJKoZIhvcNAQEBBQADggEPADCC
-AQoCggEBANyMVzoNzegwhBKr2Ml3Re/KZZO0Mhv4RFuLdNu2pf+fuDTJU4DQx3Iu
-hAu4F20Yo2w6AZyCXJuFeCGF2gcme0Sk9tlS55xRp4WM1kFX+u+Qg43ccdiFS4cE
-QKbqCffeDGN680/XAwawPNrdbVCTmJiyFO6Yw6ppo+3ggquJs5CKwXWxlQzyMANq
-bmrPDXLb3c85fR98vfUNQypgxnnbCWxpItyprB/gDx+/dxn2BggQ90gBHqqlRndz
-+Rt7oO0EJHvn0VHFkLYapPjiCPwC5H6T5MZ0tmudUmjF3hBFFePCsQzufZPweBkD
-1mvbdtnm8+DEYJc5XCstZTQVKU3ZHEMCAwEAAaNTMFEwHQYDVR0OBBYEFAX/6ghL
-HNt6p9cS671yS7Hl/98nMB8GA1UdIwQYMBaAFAX/6ghLHNt6p9cS671yS7Hl/98n
-MA8GA1UdEwEB/wQFMAMBAf8wDQYJKoZIhvcNAQELBQADggEBAMdngL/b3l17JTVK
-UETXgzbPiAS9Jym10+h+iKXJtEpGHamWGiX0kQuboW2OMlW6zdyNbU5QPu0TCsLS
-5djuM3tbkxCWmhCgL65eon5WCEZnqsny5GVZefvcPTJ7on2JwMzouLAudwiRfYF3
-jqkrgovm7Bn1YwpWZmmYv+7dUZVC7M+O6lfYuqs/2AY2TW2B1VaOgvschPgpOnX6
-G3qUejLtO99TH8ip2KHllbo8IMkCTU3+nYuJYQo0kvb/0uFA7whiZe5TVu5w/+Tk
-RyPYtm1UvFehBAzPx3xHrZFZc1WW1U9YtPC1ppWkLLRdZHuePfcsfQVpUKG4DDMz
-eW8vj+Y=
------END CERTIFICATE-----
-`
+// setToken sets bearer token which allows only this operation on this file part.
+func (c *Client) setToken(req *http.Request, operation, fileName string) {
+	if c.cfg.TokenSecret != "" {
+		token := auth.StoreToken(c.cfg.TokenSecret, c.GetID(), operation, fileName, time.Now())
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
@@ -0,0 +1,260 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Transports of store server: QUIC on UDP port and TLS with HTTP/2 on TCP port of the same address.
+const (
+	transportQUIC = "quic"
+	transportTCP  = "tcp"
+)
+
+const (
+	// quicHeadStart is how long request is sent only over QUIC before it is also sent over TCP, while working transport is unknown.
+	quicHeadStart = 300 * time.Millisecond
+	// transportTTL is how long working transport is used without racing, so store doesn't stay on TCP after UDP is unblocked.
+	transportTTL = 10 * time.Minute
+)
+
+var errBodyDetached = errors.New("request body is detached from failed transport")
+
+// fallbackTransport sends requests to store server over QUIC or TCP and remembers which one works.
+type fallbackTransport struct {
+	storeID    string
+	transports map[string]http.RoundTripper
+
+	mu           sync.Mutex
+	working      string
+	workingSince time.Time
+}
+
+func newFallbackTransport(storeID string, quic, tcp http.RoundTripper) *fallbackTransport {
+	return &fallbackTransport{
+		storeID: storeID,
+		transports: map[string]http.RoundTripper{
+			transportQUIC: quic,
+			transportTCP:  tcp,
+		},
+	}
+}
+
+func (t *fallbackTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	working, expired := t.workingTransport()
+	switch {
+	case working != "" && (!expired || hasBody):
+		resp, err := t.transports[working].RoundTrip(req)
+		if err != nil && req.Context().Err() == nil {
+			// transport stopped working, the next request chooses transport again
+			t.forget(working)
+		}
+		return resp, err
+	case hasBody:
+		return t.fallback(req)
+	default:
+		return t.race(req)
+	}
+}
+
+func (t *fallbackTransport) workingTransport() (working string, expired bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.working, time.Since(t.workingSince) > transportTTL
+}
+
+func (t *fallbackTransport) remember(transport string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.working != transport {
+		slog.Info("store server transport chosen", "serverId", t.storeID, "transport", transport)
+	}
+	t.working, t.workingSince = transport, time.Now()
+}
+
+func (t *fallbackTransport) forget(transport string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.working == transport {
+		t.working = ""
+	}
+}
+
+type attempt struct {
+	transport string
+	resp      *http.Response
+	err       error
+}
+
+// race sends request over QUIC and over TCP after head start or QUIC failure, the first response wins and the other attempt is canceled.
+func (t *fallbackTransport) race(req *http.Request) (*http.Response, error) {
+	results := make(chan attempt, 2)
+	cancels := make(map[string]context.CancelFunc)
+	start := func(transport string) {
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels[transport] = cancel
+		go func() {
+			resp, err := t.transports[transport].RoundTrip(req.Clone(ctx))
+			results <- attempt{transport: transport, resp: resp, err: err}
+		}()
+	}
+
+	start(transportQUIC)
+	headStart := time.NewTimer(quicHeadStart)
+	defer headStart.Stop()
+
+	var errs []error
+	for finished := 0; finished < len(cancels); {
+		select {
+		case <-headStart.C:
+			if len(cancels) == 1 {
+				start(transportTCP)
+			}
+		case a := <-results:
+			finished++
+			if a.err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", a.transport, a.err))
+				if len(cancels) == 1 {
+					start(transportTCP)
+				}
+				continue
+			}
+
+			t.remember(a.transport)
+			for transport, cancel := range cancels {
+				if transport != a.transport {
+					cancel()
+				}
+			}
+			go discard(results, len(cancels)-finished)
+			a.resp.Body = &cancelBody{ReadCloser: a.resp.Body, cancel: cancels[a.transport]}
+			return a.resp, nil
+		}
+	}
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+	return nil, errors.Join(errs...)
+}
+
+// discard closes responses of attempts which lost the race.
+func discard(results <-chan attempt, n int) {
+	for i := 0; i < n; i++ {
+		if a := <-results; a.resp != nil {
+			_ = a.resp.Body.Close()
+		}
+	}
+}
+
+// cancelBody cancels context of request when its response body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// fallback sends request with body over QUIC and resends it over TCP if QUIC fails before any byte of body is read.
+func (t *fallbackTransport) fallback(req *http.Request) (*http.Response, error) {
+	body := &guardedBody{body: req.Body}
+	quicReq := req.Clone(req.Context())
+	quicReq.Body = body
+
+	resp, err := t.transports[transportQUIC].RoundTrip(quicReq)
+	if err == nil {
+		body.release()
+		t.remember(transportQUIC)
+		return resp, nil
+	}
+	if !body.detach() || req.Context().Err() != nil {
+		_ = req.Body.Close()
+		return nil, err
+	}
+
+	resp, tcpErr := t.transports[transportTCP].RoundTrip(req)
+	if tcpErr != nil {
+		return nil, errors.Join(fmt.Errorf("%s: %w", transportQUIC, err), fmt.Errorf("%s: %w", transportTCP, tcpErr))
+	}
+	t.remember(transportTCP)
+	return resp, nil
+}
+
+// guardedBody keeps request body open until it is released, so body of failed request can be resent by another transport.
+type guardedBody struct {
+	body io.ReadCloser
+
+	mu       sync.Mutex
+	reading  int
+	read     bool
+	detached bool
+	released bool
+	closed   bool
+	once     sync.Once
+}
+
+func (b *guardedBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	if b.detached {
+		b.mu.Unlock()
+		return 0, errBodyDetached
+	}
+	b.reading++
+	b.mu.Unlock()
+
+	n, err := b.body.Read(p)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reading--
+	if n > 0 {
+		b.read = true
+	}
+	return n, err
+}
+
+func (b *guardedBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	if b.read || b.released {
+		return b.closeBody()
+	}
+	return nil
+}
+
+// release gives body to transport which request succeeded, so body is closed when transport closes it.
+func (b *guardedBody) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.released = true
+	if b.closed {
+		_ = b.closeBody()
+	}
+}
+
+// detach stops reading of body by failed transport and reports if body is still unread and can be resent.
+func (b *guardedBody) detach() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.detached = true
+	return !b.read && b.reading == 0
+}
+
+func (b *guardedBody) closeBody() error {
+	var err error
+	b.once.Do(func() {
+		err = b.body.Close()
+	})
+	return err
+}
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errUDPBlocked = errors.New("udp blocked")
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// fakeTransport counts requests and answers them with response of delegate.
+type fakeTransport struct {
+	calls    atomic.Int32
+	delegate roundTripperFunc
+}
+
+func (f *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.calls.Add(1)
+	return f.delegate(req)
+}
+
+type trackedBody struct {
+	io.Reader
+	closed atomic.Bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed.Store(true)
+	return nil
+}
+
+func respond(content string) roundTripperFunc {
+	return func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: &trackedBody{Reader: strings.NewReader(content)}}, nil
+	}
+}
+
+func fail(err error) roundTripperFunc {
+	return func(*http.Request) (*http.Response, error) {
+		return nil, err
+	}
+}
+
+func readResponse(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func newGetRequest(t *testing.T) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, "https://store/api/v1/getFile/part", nil)
+	require.NoError(t, err)
+	return req
+}
+
+func newUploadRequest(t *testing.T, body io.ReadCloser) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, "https://store/api/v1/uploadFile/part", body)
+	require.NoError(t, err)
+	return req
+}
+
+func TestRaceQUICWinsWithinHeadStart(t *testing.T) {
+	quic := &fakeTransport{delegate: respond("quic")}
+	tcp := &fakeTransport{delegate: respond("tcp")}
+	transport := newFallbackTransport("store", quic, tcp)
+
+	resp, err := transport.RoundTrip(newGetRequest(t))
+	require.NoError(t, err)
+	require.Equal(t, "quic", readResponse(t, resp))
+	require.Zero(t, tcp.calls.Load())
+
+	working, expired := transport.workingTransport()
+	require.Equal(t, transportQUIC, working)
+	require.False(t, expired)
+}
+
+func TestRaceFallsBackToTCPWhenQUICFails(t *testing.T) {
+	quic := &fakeTransport{delegate: fail(errUDPBlocked)}
+	tcp := &fakeTransport{delegate: respond("tcp")}
+	transport := newFallbackTransport("store", quic, tcp)
+
+	start := time.Now()
+	resp, err := transport.RoundTrip(newGetRequest(t))
+	require.NoError(t, err)
+	require.Equal(t, "tcp", readResponse(t, resp))
+	// TCP is started right after QUIC failure without waiting for head start
+	require.Less(t, time.Since(start), quicHeadStart)
+
+	// working transport is used without racing
+	resp, err = transport.RoundTrip(newGetRequest(t))
+	require.NoError(t, err)
+	require.Equal(t, "tcp", readResponse(t, resp))
+	require.Equal(t, int32(1), quic.calls.Load())
+	require.Equal(t, int32(2), tcp.calls.Load())
+}
+
+func TestRaceClosesLoserResponse(t *testing.T) {
+	loserBody := &trackedBody{Reader: strings.NewReader("quic")}
+	quicCanceled := make(chan struct{})
+	quic := &fakeTransport{delegate: func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		close(quicCanceled)
+		// response which arrives after cancellation still has to be closed
+		return &http.Response{StatusCode: http.StatusOK, Body: loserBody}, nil
+	}}
+	tcp := &fakeTransport{delegate: respond("tcp")}
+	transport := newFallbackTransport("store", quic, tcp)
+
+	resp, err := transport.RoundTrip(newGetRequest(t))
+	require.NoError(t, err)
+	require.Equal(t, "tcp", readResponse(t, resp))
+
+	<-quicCanceled
+	require.Eventually(t, loserBody.closed.Load, time.Second, time.Millisecond)
+	working, _ := transport.workingTransport()
+	require.Equal(t, transportTCP, working)
+}
+
+func TestFallbackResendsUnreadBodyOverTCP(t *testing.T) {
+	quic := &fakeTransport{delegate: fail(errUDPBlocked)}
+	var received string
+	tcp := &fakeTransport{delegate: func(req *http.Request) (*http.Response, error) {
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		received = string(data)
+		return respond("ok")(req)
+	}}
+	transport := newFallbackTransport("store", quic, tcp)
+
+	body := &trackedBody{Reader: strings.NewReader("content")}
+	resp, err := transport.RoundTrip(newUploadRequest(t, body))
+	require.NoError(t, err)
+	require.Equal(t, "ok", readResponse(t, resp))
+	require.Equal(t, "content", received)
+	working, _ := transport.workingTransport()
+	require.Equal(t, transportTCP, working)
+}
+
+func TestFallbackDoesNotResendReadBody(t *testing.T) {
+	quic := &fakeTransport{delegate: func(req *http.Request) (*http.Response, error) {
+		_, _ = req.Body.Read(make([]byte, 3))
+		_ = req.Body.Close()
+		return nil, errUDPBlocked
+	}}
+	tcp := &fakeTransport{delegate: respond("ok")}
+	transport := newFallbackTransport("store", quic, tcp)
+
+	body := &trackedBody{Reader: strings.NewReader("content")}
+	_, err := transport.RoundTrip(newUploadRequest(t, body))
+	require.ErrorIs(t, err, errUDPBlocked)
+	require.Zero(t, tcp.calls.Load())
+	require.True(t, body.closed.Load())
+}
+
+func TestFallbackKeepsBodyOpenUntilResent(t *testing.T) {
+	var closedBeforeResend bool
+	body := &trackedBody{Reader: strings.NewReader("content")}
+	quic := &fakeTransport{delegate: func(req *http.Request) (*http.Response, error) {
+		// transports close request body even when request fails
+		_ = req.Body.Close()
+		return nil, errUDPBlocked
+	}}
+	tcp := &fakeTransport{delegate: func(req *http.Request) (*http.Response, error) {
+		closedBeforeResend = body.closed.Load()
+		return respond("ok")(req)
+	}}
+	transport := newFallbackTransport("store", quic, tcp)
+
+	resp, err := transport.RoundTrip(newUploadRequest(t, body))
+	require.NoError(t, err)
+	require.Equal(t, "ok", readResponse(t, resp))
+	require.False(t, closedBeforeResend)
+}
+
+func TestExpiredTransportRacesAgain(t *testing.T) {
+	quic := &fakeTransport{delegate: respond("quic")}
+	tcp := &fakeTransport{delegate: respond("tcp")}
+	transport := newFallbackTransport("store", quic, tcp)
+	transport.remember(transportTCP)
+
+	resp, err := transport.RoundTrip(newGetRequest(t))
+	require.NoError(t, err)
+	require.Equal(t, "tcp", readResponse(t, resp))
+	require.Zero(t, quic.calls.Load())
+
+	transport.mu.Lock()
+	transport.workingSince = time.Now().Add(-transportTTL - time.Minute)
+	transport.mu.Unlock()
+
+	// upload keeps using expired transport, it can't race
+	resp, err = transport.RoundTrip(newUploadRequest(t, io.NopCloser(strings.NewReader("content"))))
+	require.NoError(t, err)
+	require.Equal(t, "tcp", readResponse(t, resp))
+	require.Zero(t, quic.calls.Load())
+
+	resp, err = transport.RoundTrip(newGetRequest(t))
+	require.NoError(t, err)
+	require.Equal(t, "quic", readResponse(t, resp))
+	working, expired := transport.workingTransport()
+	require.Equal(t, transportQUIC, working)
+	require.False(t, expired)
+}
@@ -0,0 +1,270 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/itimofeev/yas3/api/storepb"
+	"github.com/itimofeev/yas3/internal/entity"
+	"github.com/itimofeev/yas3/internal/provider/auth"
+)
+
+// uploadChunkSize is size of data in every message of upload stream.
+const uploadChunkSize = 64 * 1024
+
+// GRPCClient is client for gRPC listener of store server, store address has grpc:// scheme.
+type GRPCClient struct {
+	client storepb.StoreClient
+	cfg    Config
+}
+
+func NewGRPC(cfg Config) (*GRPCClient, error) {
+	err := validator.New().Struct(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("config validation error: %w", err)
+	}
+
+	target, ok := strings.CutPrefix(cfg.StoreAddr, SchemeGRPC)
+	if !ok {
+		return nil, fmt.Errorf("store address %s has no %s scheme", cfg.StoreAddr, SchemeGRPC)
+	}
+	// connection is established lazily and reconnects by itself, so unavailable store doesn't fail front start
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(credentials.NewTLS(cfg.TLSConfig)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &GRPCClient{
+		client: storepb.NewStoreClient(conn),
+		cfg:    cfg,
+	}, nil
+}
+
+func (c *GRPCClient) UploadFile(ctx context.Context, fileName string, content io.Reader) error {
+	slog.Debug("starting upload file to store server", "fileName", fileName, "serverId", c.GetID())
+	ctx, cancel := context.WithCancel(c.withToken(ctx, auth.StoreOperationUpload, fileName))
+	defer cancel()
+
+	stream, err := c.client.UploadFile(ctx)
+	if err != nil {
+		return grpcError(err)
+	}
+	if err := stream.Send(&storepb.UploadFileRequest{FileName: fileName}); err != nil && !errors.Is(err, io.EOF) {
+		return grpcError(err)
+	}
+
+	buf := make([]byte, uploadChunkSize)
+	for {
+		n, readErr := io.ReadFull(content, buf)
+		if n > 0 {
+			// io.EOF from Send means that server closed the stream, the reason is returned by CloseAndRecv
+			if err := stream.Send(&storepb.UploadFileRequest{Data: buf[:n]}); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return grpcError(err)
+			}
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			// deferred cancel aborts the stream, so server discards incomplete file
+			return readErr
+		}
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		return grpcError(err)
+	}
+
+	slog.Debug("file uploaded to store server", "fileName", fileName, "serverId", c.GetID())
+	return nil
+}
+
+func (c *GRPCClient) GetFile(ctx context.Context, fileName string) (io.ReadCloser, error) {
+	content, err := c.GetFileRange(ctx, fileName, 0, -1, "")
+	if err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+func (c *GRPCClient) GetFileRange(ctx context.Context, fileName string, offset, length int64, ifRange string) (*entity.FileContent, error) {
+	ctx, cancel := context.WithCancel(c.withToken(ctx, auth.StoreOperationGet, fileName))
+	stream, err := c.client.GetFile(ctx, &storepb.GetFileRequest{
+		FileName: fileName,
+		Offset:   offset,
+		Length:   length,
+		IfRange:  ifRange,
+	})
+	if err != nil {
+		cancel()
+		return nil, grpcError(err)
+	}
+
+	// the first message with size and etag is received before returning, so errors like not found file are returned here as for HTTP/3 client
+	msg, err := stream.Recv()
+	if err != nil {
+		cancel()
+		return nil, grpcError(err)
+	}
+	return &entity.FileContent{
+		ReadCloser: &downloadStreamReader{stream: stream, cancel: cancel, buf: msg.GetData()},
+		Size:       msg.GetSize(),
+		ETag:       msg.GetEtag(),
+	}, nil
+}
+
+// downloadStreamReader reads file content from messages of download stream, Close cancels the stream.
+type downloadStreamReader struct {
+	stream storepb.Store_GetFileClient
+	cancel context.CancelFunc
+	buf    []byte
+	err    error
+}
+
+func (r *downloadStreamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		msg, err := r.stream.Recv()
+		if err != nil {
+			r.err = err
+			if !errors.Is(err, io.EOF) {
+				r.err = grpcError(err)
+			}
+			continue
+		}
+		r.buf = msg.GetData()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *downloadStreamReader) Close() error {
+	r.cancel()
+	return nil
+}
+
+func (c *GRPCClient) DeleteFile(ctx context.Context, fileName string) error {
+	ctx = c.withToken(ctx, auth.StoreOperationDelete, fileName)
+	if _, err := c.client.DeleteFile(ctx, &storepb.DeleteFileRequest{FileName: fileName}); err != nil {
+		return grpcError(err)
+	}
+	return nil
+}
+
+func (c *GRPCClient) StatFile(ctx context.Context, fileName string) (entity.FileInfo, error) {
+	ctx = c.withToken(ctx, auth.StoreOperationStat, fileName)
+	resp, err := c.client.StatFile(ctx, &storepb.StatFileRequest{FileName: fileName})
+	if err != nil {
+		return entity.FileInfo{}, grpcError(err)
+	}
+
+	return entity.FileInfo{
+		Size:     resp.GetSize(),
+		ModTime:  time.Unix(0, resp.GetModTimeUnixNano()),
+		Checksum: resp.GetChecksum(),
+	}, nil
+}
+
+func (c *GRPCClient) ListFiles(ctx context.Context, after string, limit int) ([]string, error) {
+	ctx = c.withToken(ctx, auth.StoreOperationList, "")
+	resp, err := c.client.ListFiles(ctx, &storepb.ListFilesRequest{After: after, Limit: int32(limit)})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.GetNames(), nil
+}
+
+func (c *GRPCClient) GetStats(ctx context.Context) (entity.StoreStats, error) {
+	ctx = c.withToken(ctx, auth.StoreOperationStats, "")
+	stats, err := c.client.GetStats(ctx, &storepb.GetStatsRequest{})
+	if err != nil {
+		return entity.StoreStats{}, grpcError(err)
+	}
+
+	return entity.StoreStats{
+		Space: entity.AvailableSpace{
+			Total:    stats.GetTotal(),
+			Used:     stats.GetUsed(),
+			Capacity: stats.GetCapacity(),
+
+			LogicalUsed: stats.GetLogicalUsed(),
+		},
+		FilesCount:  stats.GetFilesCount(),
+		InodesTotal: stats.GetInodesTotal(),
+		InodesFree:  stats.GetInodesFree(),
+		Labels: entity.FailureDomain{
+			Zone: stats.GetZone(),
+			Rack: stats.GetRack(),
+			Host: stats.GetHost(),
+		},
+		ReadOnly:       stats.GetReadOnly(),
+		CorruptedFiles: stats.GetCorruptedFiles(),
+	}, nil
+}
+
+func (c *GRPCClient) GetID() string {
+	return c.cfg.StoreAddr
+}
+
+// withToken adds bearer token which allows only this operation on this file part to request metadata.
+func (c *GRPCClient) withToken(ctx context.Context, operation, fileName string) context.Context {
+	if c.cfg.TokenSecret == "" {
+		return ctx
+	}
+	token := auth.StoreToken(c.cfg.TokenSecret, c.GetID(), operation, fileName, time.Now())
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// grpcError maps status returned by store server to errors of HTTP/3 client, codes without HTTP counterpart are returned as is.
+func grpcError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	var statusCode int
+	switch st.Code() {
+	case codes.NotFound:
+		return entity.ErrFileNotFound
+	case codes.Canceled:
+		return context.Canceled
+	case codes.DeadlineExceeded:
+		return context.DeadlineExceeded
+	case codes.FailedPrecondition:
+		return entity.ErrFileChanged
+	case codes.InvalidArgument:
+		statusCode = http.StatusBadRequest
+	case codes.Unauthenticated:
+		statusCode = http.StatusUnauthorized
+	case codes.PermissionDenied:
+		statusCode = http.StatusForbidden
+	case codes.AlreadyExists:
+		statusCode = http.StatusConflict
+	case codes.OutOfRange:
+		statusCode = http.StatusRequestedRangeNotSatisfiable
+	case codes.ResourceExhausted:
+		statusCode = http.StatusInsufficientStorage
+	case codes.Internal, codes.Unknown, codes.DataLoss:
+		statusCode = http.StatusInternalServerError
+	default:
+		return err
+	}
+	return fmt.Errorf("%w: %s", &entity.StoreResponseError{StatusCode: statusCode}, st.Message())
+}
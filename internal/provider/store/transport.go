@@ -0,0 +1,21 @@
+package store
+
+import (
+	"strings"
+
+	"github.com/itimofeev/yas3/internal/entity"
+)
+
+// Schemes of store address choose transport to store server.
+const (
+	SchemeHTTPS = "https://"
+	SchemeGRPC  = "grpc://"
+)
+
+// NewClient returns client of store server which uses gRPC for addresses with grpc:// scheme and HTTP/3 otherwise.
+func NewClient(cfg Config) (entity.StoreClient, error) {
+	if strings.HasPrefix(cfg.StoreAddr, SchemeGRPC) {
+		return NewGRPC(cfg)
+	}
+	return New(cfg)
+}
@@ -0,0 +1,104 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Config refers to PEM encoded certificates and keys, every value is either path to file or PEM content itself.
+type Config struct {
+	// CertFile and KeyFile are own certificate and its key.
+	CertFile string
+	KeyFile  string
+	// CAFile is CA which signs certificates of the other side.
+	CAFile string
+}
+
+// Server returns TLS config of server. Client certificates are required and verified when CA is set.
+func Server(cfg Config) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("server certificate and key are required")
+	}
+	cert, err := loadKeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS13,
+	}
+	if cfg.CAFile != "" {
+		if tlsConfig.ClientCAs, err = loadCertPool(cfg.CAFile); err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// Client returns TLS config of client which verifies server with CA or system CAs and sends certificate when it is set.
+func Client(cfg Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS13,
+	}
+
+	var err error
+	if cfg.CAFile != "" {
+		if tlsConfig.RootCAs, err = loadCertPool(cfg.CAFile); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := loadKeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func loadKeyPair(certFile, keyFile string) (tls.Certificate, error) {
+	certPEM, err := loadPEM(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := loadPEM(keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	return cert, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	caPEM, err := loadPEM(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("no CA certificates found")
+	}
+	return pool, nil
+}
+
+// loadPEM returns value itself if it is PEM content, or content of file otherwise.
+func loadPEM(value string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return []byte(value), nil
+	}
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PEM file: %w", err)
+	}
+	return data, nil
+}
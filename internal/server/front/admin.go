@@ -0,0 +1,133 @@
+package front
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/itimofeev/yas3/internal/entity"
+	"github.com/itimofeev/yas3/internal/provider/auth"
+)
+
+type policyRequest struct {
+	Admin      bool     `json:"admin"`
+	ReadOnly   bool     `json:"readOnly"`
+	Operations []string `json:"operations" validate:"dive,oneof=upload get"`
+	Prefixes   []string `json:"prefixes"`
+}
+
+type createKeyResponse struct {
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+}
+
+// createKeyHandler generates new access key with policy from request body, secret key is returned only once.
+func (s *Server) createKeyHandler(resp http.ResponseWriter, req *http.Request) {
+	policy, ok := s.decodePolicy(resp, req)
+	if !ok {
+		return
+	}
+
+	key, err := auth.NewAccessKey()
+	if err != nil {
+		s.error(req, resp, err)
+		return
+	}
+	if err := s.cfg.AccessKeys.SaveAccessKeyWithPolicy(key, policy); err != nil {
+		s.error(req, resp, err)
+		return
+	}
+
+	writeJSONResponse(resp, createKeyResponse{AccessKey: key.AccessKey, SecretKey: key.SecretKey})
+}
+
+func (s *Server) getPolicyHandler(resp http.ResponseWriter, req *http.Request) {
+	policy, err := s.cfg.AccessKeys.GetPolicy(chi.URLParam(req, "accessKey"))
+	if errors.Is(err, entity.ErrPolicyNotFound) {
+		writeErrResponse(resp, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.error(req, resp, err)
+		return
+	}
+
+	writeJSONResponse(resp, policyRequest(policy))
+}
+
+// putPolicyHandler replaces policy of existing access key.
+func (s *Server) putPolicyHandler(resp http.ResponseWriter, req *http.Request) {
+	accessKey := chi.URLParam(req, "accessKey")
+	if _, err := s.cfg.AccessKeys.GetAccessKey(accessKey); err != nil {
+		if errors.Is(err, entity.ErrAccessKeyNotFound) {
+			writeErrResponse(resp, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.error(req, resp, err)
+		return
+	}
+
+	policy, ok := s.decodePolicy(resp, req)
+	if !ok {
+		return
+	}
+	if err := s.cfg.AccessKeys.SavePolicy(accessKey, policy); err != nil {
+		s.error(req, resp, err)
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+// setKeyDisabledHandler returns handler which disables or enables access key, disabled key is kept with its policy.
+func (s *Server) setKeyDisabledHandler(disabled bool) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		err := s.cfg.AccessKeys.SetAccessKeyDisabled(chi.URLParam(req, "accessKey"), disabled)
+		if errors.Is(err, entity.ErrAccessKeyNotFound) {
+			writeErrResponse(resp, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.error(req, resp, err)
+			return
+		}
+
+		_, _ = resp.Write([]byte("ok"))
+	}
+}
+
+// deleteKeyHandler deletes access key with its policy.
+func (s *Server) deleteKeyHandler(resp http.ResponseWriter, req *http.Request) {
+	err := s.cfg.AccessKeys.DeleteAccessKey(chi.URLParam(req, "accessKey"))
+	if errors.Is(err, entity.ErrAccessKeyNotFound) {
+		writeErrResponse(resp, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.error(req, resp, err)
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+func (s *Server) decodePolicy(resp http.ResponseWriter, req *http.Request) (entity.Policy, bool) {
+	var policy policyRequest
+	if err := json.NewDecoder(req.Body).Decode(&policy); err != nil {
+		writeErrResponse(resp, "invalid policy: "+err.Error(), http.StatusBadRequest)
+		return entity.Policy{}, false
+	}
+	if err := validator.New().Struct(policy); err != nil {
+		writeErrResponse(resp, "invalid policy: "+err.Error(), http.StatusBadRequest)
+		return entity.Policy{}, false
+	}
+	return entity.Policy(policy), true
+}
+
+func writeJSONResponse(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
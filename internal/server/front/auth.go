@@ -0,0 +1,201 @@
+package front
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/itimofeev/yas3/internal/entity"
+	"github.com/itimofeev/yas3/internal/provider/auth"
+)
+
+type accessKeys interface {
+	GetAccessKey(accessKey string) (entity.AccessKey, error)
+	SaveAccessKeyWithPolicy(key entity.AccessKey, policy entity.Policy) error
+	SetAccessKeyDisabled(accessKey string, disabled bool) error
+	DeleteAccessKey(accessKey string) error
+	GetPolicy(accessKey string) (entity.Policy, error)
+	SavePolicy(accessKey string, policy entity.Policy) error
+}
+
+var (
+	errUnauthenticated = errors.New("unauthenticated")
+	errForbidden       = errors.New("forbidden")
+)
+
+type policyCtxKey struct{}
+
+// authenticate rejects requests not signed by known access key within max clock skew, key and its policy are put to context.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		key, err := s.verifySignature(req)
+		if err != nil {
+			s.error(req, resp, err)
+			return
+		}
+
+		var policy *entity.Policy
+		keyPolicy, err := s.cfg.AccessKeys.GetPolicy(key.AccessKey)
+		switch {
+		case err == nil:
+			policy = &keyPolicy
+		case !errors.Is(err, entity.ErrPolicyNotFound):
+			s.error(req, resp, err)
+			return
+		}
+		ctx := context.WithValue(req.Context(), policyCtxKey{}, policy)
+		ctx = context.WithValue(ctx, accessKeyCtxKey{}, key.AccessKey)
+		next.ServeHTTP(resp, req.WithContext(ctx))
+	})
+}
+
+// authenticateFileRequest lets through request with presigned URL valid for method and file of the route, or authenticates it.
+func (s *Server) authenticateFileRequest(next http.Handler) http.Handler {
+	authenticated := s.authenticate(next)
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if isPresigned(req) {
+			maxSize, err := s.verifyPresigned(req, chi.URLParam(req, "fileID"))
+			if err != nil {
+				s.error(req, resp, err)
+				return
+			}
+			next.ServeHTTP(resp, req.WithContext(context.WithValue(req.Context(), presignedCtxKey{}, maxSize)))
+			return
+		}
+		if s.cfg.AccessKeys == nil {
+			next.ServeHTTP(resp, req)
+			return
+		}
+		authenticated.ServeHTTP(resp, req)
+	})
+}
+
+func (s *Server) verifySignature(req *http.Request) (entity.AccessKey, error) {
+	accessKey := req.Header.Get(auth.AccessKeyHeader)
+	signature := req.Header.Get(auth.SignatureHeader)
+	if accessKey == "" || signature == "" {
+		return entity.AccessKey{}, fmt.Errorf("%w: request is not signed", errUnauthenticated)
+	}
+
+	timestamp, err := strconv.ParseInt(req.Header.Get(auth.TimestampHeader), 10, 64)
+	if err != nil {
+		return entity.AccessKey{}, fmt.Errorf("%w: invalid timestamp", errUnauthenticated)
+	}
+	if skew := time.Since(time.Unix(timestamp, 0)).Abs(); skew > s.cfg.AuthMaxClockSkew {
+		return entity.AccessKey{}, fmt.Errorf("%w: request timestamp is out of allowed window", errUnauthenticated)
+	}
+
+	key, err := s.cfg.AccessKeys.GetAccessKey(accessKey)
+	if errors.Is(err, entity.ErrAccessKeyNotFound) {
+		return entity.AccessKey{}, fmt.Errorf("%w: unknown access key", errUnauthenticated)
+	}
+	if err != nil {
+		return entity.AccessKey{}, err
+	}
+
+	expected := auth.Sign(key.SecretKey, req, timestamp)
+	if !auth.SignatureMatches(signature, expected) {
+		return entity.AccessKey{}, fmt.Errorf("%w: signature doesn't match", errUnauthenticated)
+	}
+	if key.Disabled {
+		return entity.AccessKey{}, fmt.Errorf("%w: access key is disabled", errForbidden)
+	}
+
+	contentHash := req.Header.Get(auth.ContentSha256Header)
+	if contentHash != auth.UnsignedPayload {
+		if _, err := hex.DecodeString(contentHash); err != nil || len(contentHash) != 2*sha256.Size {
+			return entity.AccessKey{}, fmt.Errorf("%w: invalid content hash", errUnauthenticated)
+		}
+		req.Body = &hashedBody{body: req.Body, hash: sha256.New(), expected: contentHash}
+	}
+	if !s.signatures.add(signature, time.Unix(timestamp, 0).Add(s.cfg.AuthMaxClockSkew)) {
+		return entity.AccessKey{}, fmt.Errorf("%w: signature is already used", errUnauthenticated)
+	}
+	return key, nil
+}
+
+// hashedBody returns error instead of EOF when body doesn't match signed content hash.
+type hashedBody struct {
+	body     io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+func (b *hashedBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.hash.Write(p[:n])
+	if errors.Is(err, io.EOF) && hex.EncodeToString(b.hash.Sum(nil)) != b.expected {
+		return n, fmt.Errorf("%w: body doesn't match signed content hash", errUnauthenticated)
+	}
+	return n, err
+}
+
+func (b *hashedBody) Close() error {
+	return b.body.Close()
+}
+
+// usedSignatures keeps signatures of accepted requests until they expire, so captured request can't be replayed.
+type usedSignatures struct {
+	mu        sync.Mutex
+	expires   map[string]time.Time
+	lastSweep time.Time
+}
+
+func newUsedSignatures() *usedSignatures {
+	return &usedSignatures{expires: make(map[string]time.Time), lastSweep: time.Now()}
+}
+
+// add reports false if signature is already used.
+func (u *usedSignatures) add(signature string, expires time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(u.lastSweep) > time.Minute {
+		for used, usedExpires := range u.expires {
+			if now.After(usedExpires) {
+				delete(u.expires, used)
+			}
+		}
+		u.lastSweep = now
+	}
+	if _, ok := u.expires[signature]; ok {
+		return false
+	}
+	u.expires[signature] = expires
+	return true
+}
+
+// authorize checks policy of access key which signed request, every request is allowed when authentication is disabled.
+func (s *Server) authorize(req *http.Request, operation, fileID string) error {
+	if s.cfg.AccessKeys == nil {
+		return nil
+	}
+	policy, _ := req.Context().Value(policyCtxKey{}).(*entity.Policy)
+	if !auth.Allowed(policy, operation, fileID) {
+		return fmt.Errorf("%w: %s of file %s is not allowed", errForbidden, operation, fileID)
+	}
+	return nil
+}
+
+// requireAdmin lets through only requests signed by access key with admin policy.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		policy, _ := req.Context().Value(policyCtxKey{}).(*entity.Policy)
+		if policy == nil || !policy.Admin {
+			s.error(req, resp, fmt.Errorf("%w: admin policy is required", errForbidden))
+			return
+		}
+		next.ServeHTTP(resp, req)
+	})
+}
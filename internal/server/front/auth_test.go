@@ -0,0 +1,94 @@
+package front
+
+import (
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itimofeev/yas3/internal/entity"
+	"github.com/itimofeev/yas3/internal/provider/auth"
+)
+
+func TestPresignedURLDoesNotBypassAuthentication(t *testing.T) {
+	files := &fakeFileRegistry{}
+	s := newTestServer(t, readerKeys(), files)
+	fileID := uuid.NewString()
+	presigned := auth.Presign("presign secret", http.MethodGet, fileID, time.Now().Add(time.Hour).Unix(), 0)
+
+	t.Run("presign request with signature param", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/presign/"+fileID+"?method=GET&expiresIn=1h&"+presigned.Encode(), nil)
+		require.Equal(t, http.StatusUnauthorized, serve(s, req).Code)
+	})
+	t.Run("presigned URL of another file", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/getFile/"+uuid.NewString()+"?"+presigned.Encode(), nil)
+		require.Equal(t, http.StatusForbidden, serve(s, req).Code)
+	})
+	t.Run("presigned URL with another method", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/uploadFile/"+fileID+"?"+presigned.Encode(), nil)
+		require.Equal(t, http.StatusForbidden, serve(s, req).Code)
+	})
+	t.Run("unsigned request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/getFile/"+fileID, nil)
+		require.Equal(t, http.StatusUnauthorized, serve(s, req).Code)
+	})
+	require.Empty(t, files.requested)
+
+	t.Run("presigned URL", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/getFile/"+fileID+"?"+presigned.Encode(), nil)
+		serve(s, req)
+		require.Equal(t, []string{fileID}, files.requested)
+	})
+	t.Run("signed presign request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/presign/"+fileID+"?method=GET&expiresIn=1h", nil)
+		auth.SignRequest(req, "reader", "reader secret", time.Now())
+		require.Equal(t, http.StatusOK, serve(s, req).Code)
+	})
+}
+
+func TestSignedRequestCanNotBeReplayed(t *testing.T) {
+	files := &fakeFileRegistry{}
+	s := newTestServer(t, readerKeys(), files)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/getFile/"+uuid.NewString(), nil)
+	auth.SignRequest(req, "reader", "reader secret", time.Now())
+	serve(s, req)
+	require.Len(t, files.requested, 1)
+
+	replayed := httptest.NewRequest(http.MethodGet, req.URL.String(), nil)
+	replayed.Header = req.Header.Clone()
+	require.Equal(t, http.StatusUnauthorized, serve(s, replayed).Code)
+	require.Len(t, files.requested, 1)
+}
+
+func TestHashedBody(t *testing.T) {
+	read := func(content, signed string) error {
+		body := &hashedBody{body: io.NopCloser(strings.NewReader(content)), hash: sha256.New(), expected: auth.ContentSha256([]byte(signed))}
+		_, err := io.ReadAll(body)
+		return err
+	}
+	require.NoError(t, read("content", "content"))
+	require.ErrorIs(t, read("replaced", "content"), errUnauthenticated)
+}
+
+func TestMetricsRequireAdmin(t *testing.T) {
+	keys := readerKeys()
+	keys.keys["admin"] = entity.AccessKey{AccessKey: "admin", SecretKey: "admin secret"}
+	keys.policies["admin"] = entity.Policy{Admin: true}
+	s := newTestServer(t, keys, &fakeFileRegistry{})
+	metrics := func(sign func(req *http.Request)) int {
+		req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+		sign(req)
+		return serve(s, req).Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, metrics(func(*http.Request) {}))
+	require.Equal(t, http.StatusForbidden, metrics(func(req *http.Request) { auth.SignRequest(req, "reader", "reader secret", time.Now()) }))
+	require.Equal(t, http.StatusOK, metrics(func(req *http.Request) { auth.SignRequest(req, "admin", "admin secret", time.Now()) }))
+}
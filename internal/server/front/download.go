@@ -0,0 +1,89 @@
+package front
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+
+	"github.com/itimofeev/yas3/internal/entity"
+	"github.com/itimofeev/yas3/internal/provider/encryption"
+)
+
+// maxResumeAttempts is how many times broken download of one file part is resumed.
+const maxResumeAttempts = 3
+
+// partDownload is progress of file part download kept between resumed attempts.
+type partDownload struct {
+	// copied is count of bytes written to w, plaintext ones for encrypted part.
+	copied int64
+	// size and etag of stored part are known from the first response, resumed download requires the same etag.
+	size int64
+	etag string
+}
+
+// copyPart copies file part from its replicas to w, broken download is resumed from the next replica with If-Range of the first response.
+func copyPart(ctx context.Context, w io.Writer, replicas []entity.StoreClient, fileName string, cipher *encryption.Cipher, partNumber int) error {
+	download := &partDownload{size: -1}
+	for attempt := 0; ; attempt++ {
+		storeClient := replicas[attempt%len(replicas)]
+		readErr, writeErr := copyPartFrom(ctx, w, storeClient, fileName, cipher, partNumber, download)
+		if readErr == nil || writeErr != nil {
+			return writeErr
+		}
+		if attempt >= maxResumeAttempts+len(replicas)-1 || ctx.Err() != nil || errors.Is(readErr, entity.ErrFileChanged) {
+			return readErr
+		}
+		if len(replicas) == 1 && (errors.Is(readErr, entity.ErrFileNotFound) || errors.Is(readErr, encryption.ErrAuthentication)) {
+			return readErr
+		}
+		slog.Warn("file part download is broken, resuming", "fileName", fileName, "serverId", storeClient.GetID(), "offset", download.copied, "err", readErr)
+	}
+}
+
+// copyPartFrom copies file part starting from copied offset and separates errors of store server from errors of w.
+func copyPartFrom(ctx context.Context, w io.Writer, storeClient entity.StoreClient, fileName string, cipher *encryption.Cipher, partNumber int, download *partDownload) (readErr, writeErr error) {
+	offset := download.copied
+	if cipher != nil {
+		offset = cipher.CiphertextOffset(download.copied)
+	}
+	if download.size >= 0 && offset >= download.size {
+		// the whole part is received, store server failed after the last byte
+		return nil, nil
+	}
+	filePart, err := storeClient.GetFileRange(ctx, fileName, offset, -1, download.etag)
+	if err != nil {
+		return err, nil
+	}
+	defer filePart.Close()
+	if download.etag == "" {
+		download.size, download.etag = filePart.Size, filePart.ETag
+	}
+
+	var content io.Reader = filePart
+	if cipher != nil {
+		content = cipher.DecryptReader(partNumber, filePart, download.copied)
+	}
+
+	writer := &trackingWriter{w: w}
+	n, err := io.Copy(writer, content)
+	download.copied += n
+	if writer.err != nil {
+		return nil, writer.err
+	}
+	return err, nil
+}
+
+// trackingWriter remembers write error, so it can be distinguished from read error after io.Copy.
+type trackingWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (t *trackingWriter) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	if err != nil {
+		t.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,95 @@
+package front
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/itimofeev/yas3/internal/entity"
+)
+
+var errBrokenStream = errors.New("broken stream")
+
+// fakeStore serves one file part, its stream breaks after breakAfter bytes when breakAfter is not negative.
+type fakeStore struct {
+	entity.StoreClient
+	id         string
+	content    []byte
+	etag       string
+	breakAfter int
+	requests   []fakeRangeRequest
+}
+
+type fakeRangeRequest struct {
+	offset  int64
+	ifRange string
+}
+
+func (s *fakeStore) GetID() string {
+	return s.id
+}
+
+func (s *fakeStore) GetFileRange(_ context.Context, _ string, offset, _ int64, ifRange string) (*entity.FileContent, error) {
+	s.requests = append(s.requests, fakeRangeRequest{offset: offset, ifRange: ifRange})
+	if ifRange != "" && ifRange != s.etag {
+		return nil, entity.ErrFileChanged
+	}
+	if offset >= int64(len(s.content)) {
+		return nil, &entity.StoreResponseError{StatusCode: 416}
+	}
+
+	var r io.Reader = bytes.NewReader(s.content[offset:])
+	if s.breakAfter >= 0 {
+		r = io.MultiReader(io.LimitReader(r, int64(s.breakAfter)), &errReader{err: errBrokenStream})
+	}
+	return &entity.FileContent{ReadCloser: io.NopCloser(r), Size: int64(len(s.content)), ETag: s.etag}, nil
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestCopyPartResumesWithIfRange(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 10))
+	broken := &fakeStore{id: "broken", content: content, etag: `"sum"`, breakAfter: 30}
+	healthy := &fakeStore{id: "healthy", content: content, etag: `"sum"`, breakAfter: -1}
+
+	var out bytes.Buffer
+	err := copyPart(context.Background(), &out, []entity.StoreClient{broken, healthy}, "file.0", nil, 0)
+	require.NoError(t, err)
+	require.Equal(t, content, out.Bytes())
+	require.Equal(t, []fakeRangeRequest{{offset: 0}}, broken.requests)
+	require.Equal(t, []fakeRangeRequest{{offset: 30, ifRange: `"sum"`}}, healthy.requests)
+}
+
+func TestCopyPartSkipsResumeOfCompletePart(t *testing.T) {
+	content := []byte("complete content")
+	broken := &fakeStore{id: "broken", content: content, etag: `"sum"`, breakAfter: len(content)}
+	other := &fakeStore{id: "other", content: content, etag: `"sum"`, breakAfter: -1}
+
+	var out bytes.Buffer
+	err := copyPart(context.Background(), &out, []entity.StoreClient{broken, other}, "file.0", nil, 0)
+	require.NoError(t, err)
+	require.Equal(t, content, out.Bytes())
+	require.Empty(t, other.requests)
+}
+
+func TestCopyPartStopsWhenReplicaDiffers(t *testing.T) {
+	broken := &fakeStore{id: "broken", content: []byte("first content"), etag: `"first"`, breakAfter: 5}
+	other := &fakeStore{id: "other", content: []byte("other content"), etag: `"other"`, breakAfter: -1}
+
+	var out bytes.Buffer
+	err := copyPart(context.Background(), &out, []entity.StoreClient{broken, other}, "file.0", nil, 0)
+	require.ErrorIs(t, err, entity.ErrFileChanged)
+	require.Equal(t, []byte("first"), out.Bytes())
+	require.Len(t, other.requests, 1)
+}
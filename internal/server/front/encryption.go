@@ -0,0 +1,113 @@
+package front
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/itimofeev/yas3/internal/entity"
+	"github.com/itimofeev/yas3/internal/provider/encryption"
+)
+
+// customerKeyHeader contains base64 encoded 256-bit key provided by customer, it is never stored.
+const customerKeyHeader = "X-Customer-Key"
+
+type keyWrapper interface {
+	NewDataKey() (key, wrapped []byte, err error)
+	UnwrapKey(wrapped []byte) ([]byte, error)
+}
+
+var (
+	errEncryptionNotConfigured = errors.New("file is encrypted, but encryption is not configured")
+	errInvalidCustomerKey      = errors.New("invalid customer key")
+	errCustomerKeyMismatch     = errors.New("customer key doesn't match key the file was uploaded with")
+)
+
+// newFileCipher generates data key of new file wrapped by customer key or master key, nil cipher is returned when encryption is disabled.
+func (s *Server) newFileCipher(req *http.Request, fileID string) (*encryption.Cipher, *entity.Encryption, error) {
+	var (
+		wrapper     keyWrapper = s.cfg.KeyWrapper
+		fingerprint string
+	)
+	if req.Header.Get(customerKeyHeader) != "" {
+		customerKey, err := parseCustomerKey(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		if wrapper, err = encryption.CustomerKeyWrapper(customerKey, fileID); err != nil {
+			return nil, nil, err
+		}
+		fingerprint = encryption.CustomerKeyFingerprint(customerKey, fileID)
+	} else if s.cfg.KeyWrapper == nil {
+		return nil, nil, nil
+	}
+
+	key, wrapped, err := wrapper.NewDataKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	cipher, err := encryption.NewCipher(key, encryption.DefaultChunkSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cipher, &entity.Encryption{
+		Scheme:         encryption.SchemeChunkedAESGCM,
+		ChunkSize:      encryption.DefaultChunkSize,
+		WrappedKey:     wrapped,
+		KeyFingerprint: fingerprint,
+	}, nil
+}
+
+// fileCipher returns cipher with data key of uploaded file, nil cipher is returned for files stored in plaintext.
+func (s *Server) fileCipher(req *http.Request, fileID string, meta entity.FileMeta) (*encryption.Cipher, error) {
+	enc := meta.Encryption
+	if req.Header.Get(customerKeyHeader) != "" && (enc == nil || enc.KeyFingerprint == "") {
+		return nil, fmt.Errorf("%w: file is not encrypted with customer key", errInvalidCustomerKey)
+	}
+	if enc == nil {
+		return nil, nil
+	}
+	if enc.Scheme != encryption.SchemeChunkedAESGCM {
+		return nil, fmt.Errorf("unknown encryption scheme %s", enc.Scheme)
+	}
+
+	if enc.KeyFingerprint != "" {
+		customerKey, err := parseCustomerKey(req)
+		if err != nil {
+			return nil, err
+		}
+		if !encryption.FingerprintMatches(encryption.CustomerKeyFingerprint(customerKey, fileID), enc.KeyFingerprint) {
+			return nil, errCustomerKeyMismatch
+		}
+		wrapper, err := encryption.CustomerKeyWrapper(customerKey, fileID)
+		if err != nil {
+			return nil, err
+		}
+		key, err := wrapper.UnwrapKey(enc.WrappedKey)
+		if err != nil {
+			return nil, err
+		}
+		return encryption.NewCipher(key, enc.ChunkSize)
+	}
+
+	if s.cfg.KeyWrapper == nil {
+		return nil, errEncryptionNotConfigured
+	}
+	key, err := s.cfg.KeyWrapper.UnwrapKey(enc.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	return encryption.NewCipher(key, enc.ChunkSize)
+}
+
+func parseCustomerKey(req *http.Request) ([]byte, error) {
+	value := req.Header.Get(customerKeyHeader)
+	if value == "" {
+		return nil, fmt.Errorf("%w: file is encrypted with customer key, %s header is required", errInvalidCustomerKey, customerKeyHeader)
+	}
+	key, err := encryption.ParseCustomerKey(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidCustomerKey, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,86 @@
+package front
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/itimofeev/yas3/internal/entity"
+	"github.com/itimofeev/yas3/internal/provider/auth"
+)
+
+var errTestFileNotFound = errors.New("test file not found")
+
+type fakeAccessKeys struct {
+	accessKeys
+	keys     map[string]entity.AccessKey
+	policies map[string]entity.Policy
+}
+
+func (f *fakeAccessKeys) GetAccessKey(accessKey string) (entity.AccessKey, error) {
+	key, ok := f.keys[accessKey]
+	if !ok {
+		return entity.AccessKey{}, entity.ErrAccessKeyNotFound
+	}
+	return key, nil
+}
+
+func (f *fakeAccessKeys) GetPolicy(accessKey string) (entity.Policy, error) {
+	policy, ok := f.policies[accessKey]
+	if !ok {
+		return entity.Policy{}, entity.ErrPolicyNotFound
+	}
+	return policy, nil
+}
+
+// fakeFileRegistry records files requested by handlers, which means request passed authentication.
+type fakeFileRegistry struct {
+	fileRegistry
+	requested []string
+}
+
+func (f *fakeFileRegistry) GetFile(fileID string) (entity.FileMeta, error) {
+	f.requested = append(f.requested, fileID)
+	return entity.FileMeta{}, errTestFileNotFound
+}
+
+func newTestServer(t *testing.T, keys *fakeAccessKeys, files *fakeFileRegistry) *Server {
+	t.Helper()
+	cfg := Config{
+		Addr:              ":0",
+		ReadTimeout:       time.Second,
+		WriteTimeout:      time.Second,
+		MaxFileSizeBytes:  1 << 20,
+		PartsCount:        1,
+		ReplicationFactor: 1,
+		ServersRegistry:   struct{ storeServersRegistry }{},
+		FileRegistry:      files,
+		AuthMaxClockSkew:  time.Minute,
+		PresignSecret:     "presign secret",
+		PresignMaxExpiry:  time.Hour,
+	}
+	if keys != nil {
+		cfg.AccessKeys = keys
+	}
+	s, err := New(cfg)
+	require.NoError(t, err)
+	return s
+}
+
+func serve(s *Server, req *http.Request) *httptest.ResponseRecorder {
+	resp := httptest.NewRecorder()
+	s.srv.Handler.ServeHTTP(resp, req)
+	return resp
+}
+
+// readerKeys returns access key "reader" with secret "reader secret", which can get any file.
+func readerKeys() *fakeAccessKeys {
+	return &fakeAccessKeys{
+		keys:     map[string]entity.AccessKey{"reader": {AccessKey: "reader", SecretKey: "reader secret"}},
+		policies: map[string]entity.Policy{"reader": {Operations: []string{auth.OperationGet}, Prefixes: []string{""}}},
+	}
+}
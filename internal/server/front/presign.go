@@ -0,0 +1,106 @@
+package front
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/itimofeev/yas3/internal/provider/auth"
+)
+
+type presignResponse struct {
+	URL     string `json:"url"`
+	Expires int64  `json:"expires"`
+}
+
+// presignHandler mints URL which allows to get or upload one file until expiry without credentials.
+func (s *Server) presignHandler(resp http.ResponseWriter, req *http.Request) {
+	fileID, err := uuid.Parse(chi.URLParam(req, "fileID"))
+	if err != nil {
+		s.error(req, resp, err)
+		return
+	}
+
+	query := req.URL.Query()
+	expiresIn, err := time.ParseDuration(query.Get("expiresIn"))
+	if err != nil || expiresIn <= 0 || expiresIn > s.cfg.PresignMaxExpiry {
+		writeErrResponse(resp, fmt.Sprintf("expiresIn must be positive duration up to %s", s.cfg.PresignMaxExpiry), http.StatusBadRequest)
+		return
+	}
+	var maxSize int64
+	if maxSizeStr := query.Get("maxSize"); maxSizeStr != "" {
+		if maxSize, err = strconv.ParseInt(maxSizeStr, 10, 64); err != nil || maxSize <= 0 {
+			writeErrResponse(resp, "invalid maxSize "+maxSizeStr, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var path, operation string
+	switch method := query.Get("method"); method {
+	case http.MethodGet:
+		path, operation = "/api/v1/getFile/", auth.OperationGet
+	case http.MethodPost:
+		path, operation = "/api/v1/uploadFile/", auth.OperationUpload
+	default:
+		writeErrResponse(resp, "unsupported method "+method, http.StatusBadRequest)
+		return
+	}
+	if err := s.authorize(req, operation, fileID.String()); err != nil {
+		s.error(req, resp, err)
+		return
+	}
+
+	expires := time.Now().Add(expiresIn).Unix()
+	presigned := auth.Presign(s.cfg.PresignSecret, query.Get("method"), fileID.String(), expires, maxSize)
+	writeJSONResponse(resp, presignResponse{
+		URL:     path + fileID.String() + "?" + presigned.Encode(),
+		Expires: expires,
+	})
+}
+
+func isPresigned(req *http.Request) bool {
+	return req.URL.Query().Has(auth.PresignSignatureParam)
+}
+
+// verifyPresigned checks presigned URL of request and returns max size of uploaded file, 0 means no limit.
+func (s *Server) verifyPresigned(req *http.Request, fileID string) (int64, error) {
+	if s.cfg.PresignSecret == "" {
+		return 0, fmt.Errorf("%w: presigned URLs are not configured", errForbidden)
+	}
+
+	query := req.URL.Query()
+	expires, err := strconv.ParseInt(query.Get(auth.PresignExpiresParam), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid expiry of presigned URL", errForbidden)
+	}
+	var maxSize int64
+	if maxSizeStr := query.Get(auth.PresignMaxSizeParam); maxSizeStr != "" {
+		if maxSize, err = strconv.ParseInt(maxSizeStr, 10, 64); err != nil {
+			return 0, fmt.Errorf("%w: invalid max size of presigned URL", errForbidden)
+		}
+	}
+
+	expected := auth.PresignSignature(s.cfg.PresignSecret, req.Method, fileID, expires, maxSize)
+	if !auth.SignatureMatches(query.Get(auth.PresignSignatureParam), expected) {
+		return 0, fmt.Errorf("%w: signature of presigned URL doesn't match", errForbidden)
+	}
+	if time.Now().Unix() > expires {
+		return 0, fmt.Errorf("%w: presigned URL is expired", errForbidden)
+	}
+	return maxSize, nil
+}
+
+// presignedCtxKey keeps max size of uploaded file from presigned URL verified by authenticateFileRequest.
+type presignedCtxKey struct{}
+
+// checkAccess checks presigned URL or policy of access key and returns max size of uploaded file, 0 means no limit.
+func (s *Server) checkAccess(req *http.Request, operation, fileID string) (maxSize int64, err error) {
+	if maxSize, ok := req.Context().Value(presignedCtxKey{}).(int64); ok {
+		return maxSize, nil
+	}
+	return 0, s.authorize(req, operation, fileID)
+}
@@ -0,0 +1,270 @@
+package front
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimits are limits of every client identified by access key or IP address, zero value disables limit.
+type RateLimits struct {
+	// RequestsPerSecond is average rate of API requests, RequestsBurst is how many requests can be made at once above it.
+	RequestsPerSecond float64 `validate:"gte=0"`
+	RequestsBurst     int     `validate:"required_with=RequestsPerSecond,gte=0"`
+	// ConcurrentUploads and ConcurrentDownloads limit how many files client uploads and downloads at once.
+	ConcurrentUploads   int `validate:"gte=0"`
+	ConcurrentDownloads int `validate:"gte=0"`
+	// BytesPerSecond limits throughput of file content uploaded and downloaded by client.
+	BytesPerSecond int `validate:"gte=0"`
+}
+
+const (
+	// concurrencyRetryAfter is suggested to clients above concurrency limit, it is unknown when their transfers end.
+	concurrencyRetryAfter = time.Second
+	// clientIdleTTL is how long limiters of client without requests are kept.
+	clientIdleTTL = 10 * time.Minute
+)
+
+var errRateLimited = errors.New("rate limit exceeded")
+
+// rateLimitError is rejection of client request, client can retry it after retryAfter.
+type rateLimitError struct {
+	reason     string
+	retryAfter time.Duration
+}
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("%s: %s", errRateLimited, e.reason)
+}
+
+func (e *rateLimitError) Unwrap() error {
+	return errRateLimited
+}
+
+// retryAfterHeader returns value of Retry-After header in whole seconds, at least one.
+func (e *rateLimitError) retryAfterHeader() string {
+	return strconv.Itoa(max(1, int(math.Ceil(e.retryAfter.Seconds()))))
+}
+
+type streamKind int
+
+const (
+	streamUpload streamKind = iota
+	streamDownload
+)
+
+type clientLimiter struct {
+	requests *rate.Limiter
+	bytes    *rate.Limiter
+	// streams are counts of uploads and downloads in progress, guarded by mutex of clientLimiters.
+	streams  [2]int
+	lastSeen time.Time
+}
+
+// clientLimiters keeps limiters of every client which made requests recently.
+type clientLimiters struct {
+	cfg RateLimits
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+func newClientLimiters(cfg RateLimits) *clientLimiters {
+	return &clientLimiters{
+		cfg:       cfg,
+		clients:   make(map[string]*clientLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// get returns limiter of client, limiters of clients idle longer than clientIdleTTL are removed on the way.
+func (l *clientLimiters) get(client string) *clientLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > clientIdleTTL {
+		for id, c := range l.clients {
+			if c.streams == [2]int{} && now.Sub(c.lastSeen) > clientIdleTTL {
+				delete(l.clients, id)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[client]
+	if !ok {
+		c = &clientLimiter{}
+		if l.cfg.RequestsPerSecond > 0 {
+			c.requests = rate.NewLimiter(rate.Limit(l.cfg.RequestsPerSecond), l.cfg.RequestsBurst)
+		}
+		if l.cfg.BytesPerSecond > 0 {
+			c.bytes = rate.NewLimiter(rate.Limit(l.cfg.BytesPerSecond), l.cfg.BytesPerSecond)
+		}
+		l.clients[client] = c
+	}
+	c.lastSeen = now
+	return c
+}
+
+// allowRequest takes token of requests rate, rejected request is not counted.
+func (l *clientLimiters) allowRequest(client string) error {
+	if l.cfg.RequestsPerSecond == 0 {
+		return nil
+	}
+	c := l.get(client)
+	reservation := c.requests.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return &rateLimitError{reason: "too many requests", retryAfter: delay}
+	}
+	return nil
+}
+
+// acquireStream takes slot of upload or download in progress, transfer is counted also when only bytes rate is limited.
+func (l *clientLimiters) acquireStream(client string, kind streamKind) (release func(), err error) {
+	limit := l.cfg.ConcurrentUploads
+	if kind == streamDownload {
+		limit = l.cfg.ConcurrentDownloads
+	}
+	if limit == 0 && l.cfg.BytesPerSecond == 0 {
+		return func() {}, nil
+	}
+
+	c := l.get(client)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limit > 0 && c.streams[kind] >= limit {
+		return nil, &rateLimitError{reason: "too many concurrent transfers", retryAfter: concurrencyRetryAfter}
+	}
+	c.streams[kind]++
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		c.streams[kind]--
+	}, nil
+}
+
+// reader returns r which is read not faster than bytes rate of client.
+func (l *clientLimiters) reader(ctx context.Context, client string, r io.Reader) io.Reader {
+	if l.cfg.BytesPerSecond == 0 {
+		return r
+	}
+	return &throttledReader{ctx: ctx, r: r, limiter: l.get(client).bytes}
+}
+
+// writer returns w which is written not faster than bytes rate of client.
+func (l *clientLimiters) writer(ctx context.Context, client string, w io.Writer) io.Writer {
+	if l.cfg.BytesPerSecond == 0 {
+		return w
+	}
+	return &throttledWriter{ctx: ctx, w: w, limiter: l.get(client).bytes}
+}
+
+type throttledReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	// limiter can't wait for more bytes than its burst at once
+	p = p[:min(len(p), t.limiter.Burst())]
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if waitErr := t.limiter.WaitN(t.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+type throttledWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter *rate.Limiter
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		n := min(len(p), t.limiter.Burst())
+		if err := t.limiter.WaitN(t.ctx, n); err != nil {
+			return written, err
+		}
+		n, err := t.w.Write(p[:n])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+type accessKeyCtxKey struct{}
+
+// clientID identifies client by access key which signed request, or by IP address for anonymous and presigned requests.
+func clientID(req *http.Request) string {
+	if accessKey, ok := req.Context().Value(accessKeyCtxKey{}).(string); ok {
+		return "key:" + accessKey
+	}
+	return ipClientID(req)
+}
+
+func ipClientID(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// limitRequests rejects requests from IP address above requests rate with 429, it runs before authentication.
+func (s *Server) limitRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if err := s.limiters.allowRequest(ipClientID(req)); err != nil {
+			s.error(req, resp, err)
+			return
+		}
+		next.ServeHTTP(resp, req)
+	})
+}
+
+// limitKeyRequests rejects requests signed by access key above requests rate with 429, whichever address they come from.
+func (s *Server) limitKeyRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if accessKey, ok := req.Context().Value(accessKeyCtxKey{}).(string); ok {
+			if err := s.limiters.allowRequest("key:" + accessKey); err != nil {
+				s.error(req, resp, err)
+				return
+			}
+		}
+		next.ServeHTTP(resp, req)
+	})
+}
+
+// limitStreams rejects uploads or downloads of client above concurrency limit with 429.
+func (s *Server) limitStreams(kind streamKind) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			release, err := s.limiters.acquireStream(clientID(req), kind)
+			if err != nil {
+				s.error(req, resp, err)
+				return
+			}
+			defer release()
+			next.ServeHTTP(resp, req)
+		})
+	}
+}
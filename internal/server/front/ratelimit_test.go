@@ -0,0 +1,66 @@
+package front
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itimofeev/yas3/internal/provider/auth"
+)
+
+func newRateLimitedServer(t *testing.T) *Server {
+	t.Helper()
+	s := newTestServer(t, readerKeys(), &fakeFileRegistry{})
+	s.limiters = newClientLimiters(RateLimits{RequestsPerSecond: 0.001, RequestsBurst: 2})
+	return s
+}
+
+func TestRequestsWithInvalidCredentialsAreLimited(t *testing.T) {
+	for _, path := range []string{"/api/v1/getFile/" + uuid.NewString(), "/admin/v1/keys/reader/policy"} {
+		s := newRateLimitedServer(t)
+		var codes []int
+		for i := 0; i < 3; i++ {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			auth.SignRequest(req, "reader", "wrong secret", time.Now())
+			codes = append(codes, serve(s, req).Code)
+		}
+		require.Equal(t, []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}, codes, path)
+	}
+}
+
+func TestRequestsOfAccessKeyAreLimitedAcrossAddresses(t *testing.T) {
+	s := newRateLimitedServer(t)
+	var codes []int
+	for _, remoteAddr := range []string{"10.0.0.1:1000", "10.0.0.2:1000", "10.0.0.3:1000"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/getFile/"+uuid.NewString(), nil)
+		req.RemoteAddr = remoteAddr
+		auth.SignRequest(req, "reader", "reader secret", time.Now())
+		codes = append(codes, serve(s, req).Code)
+	}
+	require.Equal(t, http.StatusTooManyRequests, codes[2])
+	require.NotContains(t, codes[:2], http.StatusTooManyRequests)
+}
+
+func TestThrottledTransferKeepsLimiter(t *testing.T) {
+	l := newClientLimiters(RateLimits{BytesPerSecond: 1024})
+	release, err := l.acquireStream("ip:client", streamDownload)
+	require.NoError(t, err)
+	limiter := l.get("ip:client").bytes
+
+	l.mu.Lock()
+	l.lastSweep = time.Now().Add(-2 * clientIdleTTL)
+	l.clients["ip:client"].lastSeen = l.lastSweep
+	l.mu.Unlock()
+	require.Same(t, limiter, l.get("ip:client").bytes)
+
+	release()
+	l.mu.Lock()
+	l.lastSweep = time.Now().Add(-2 * clientIdleTTL)
+	l.clients["ip:client"].lastSeen = l.lastSweep
+	l.mu.Unlock()
+	require.NotSame(t, limiter, l.get("ip:client").bytes)
+}
@@ -2,6 +2,7 @@ package front
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -9,35 +10,56 @@ import (
 	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/itimofeev/yas3/internal/entity"
 )
 
 type storeServersRegistry interface {
-	GetServersForParts(nFileParts int64) ([]entity.StoreClient, error)
+	GetServersForParts(fileID string, nFileParts int64, replicas int) ([][]entity.StoreClient, error)
 	GetStoreClients(serverIDs []string) ([]entity.StoreClient, error)
 }
 type fileRegistry interface {
-	SaveFileParts(fileID string, serverIDs []string) error
-	GetFileParts(fileID string) ([]string, error)
+	SaveFile(fileID string, meta entity.FileMeta) error
+	GetFile(fileID string) (entity.FileMeta, error)
 	IsFileExists(fileID string) bool
 }
 
 type Config struct {
-	Addr             string               `validate:"required"`
-	ReadTimeout      time.Duration        `validate:"required"`
-	WriteTimeout     time.Duration        `validate:"required"`
-	MaxFileSizeBytes int64                `validate:"required,gt=0"`
-	PartsCount       int64                `validate:"required,gt=0"`
-	ServersRegistry  storeServersRegistry `validate:"required"`
-	FileRegistry     fileRegistry         `validate:"required"`
+	Addr             string        `validate:"required"`
+	ReadTimeout      time.Duration `validate:"required"`
+	WriteTimeout     time.Duration `validate:"required"`
+	MaxFileSizeBytes int64         `validate:"required,gt=0"`
+	PartsCount       int64         `validate:"required,gt=0"`
+	// ReplicationFactor is number of store servers which keep every file part.
+	ReplicationFactor int                  `validate:"required,gt=0"`
+	ServersRegistry   storeServersRegistry `validate:"required"`
+	FileRegistry      fileRegistry         `validate:"required"`
+	// KeyWrapper enables encryption of new files at rest, files are stored in plaintext when it is nil.
+	KeyWrapper keyWrapper
+	// AccessKeys enables authentication of API requests, requests are not authenticated when it is nil.
+	AccessKeys accessKeys
+	// AuthMaxClockSkew is how long signed request stays valid, it also limits difference between client and server clocks.
+	AuthMaxClockSkew time.Duration `validate:"required_with=AccessKeys"`
+	// PresignSecret signs presigned URLs, they are not accepted when it is empty.
+	PresignSecret string
+	// PresignMaxExpiry limits how long presigned URL is valid.
+	PresignMaxExpiry time.Duration `validate:"required_with=PresignSecret"`
+	// RateLimits limit requests and transfers of every client.
+	RateLimits RateLimits
+	// TLSConfig enables HTTPS with HTTP/2 and HTTP/3 on the same port, server listens plaintext HTTP/1.1 when it is nil.
+	TLSConfig *tls.Config
 }
 
 type Server struct {
 	srv             *http.Server
+	h3              *http3.Server
 	cfg             Config
 	serversRegistry storeServersRegistry
 	fileRegistry    fileRegistry
+	limiters        *clientLimiters
+	signatures      *usedSignatures
 }
 
 func New(cfg Config) (*Server, error) {
@@ -50,6 +72,15 @@ func New(cfg Config) (*Server, error) {
 		cfg:             cfg,
 		serversRegistry: cfg.ServersRegistry,
 		fileRegistry:    cfg.FileRegistry,
+		limiters:        newClientLimiters(cfg.RateLimits),
+		signatures:      newUsedSignatures(),
+	}
+
+	if cfg.TLSConfig != nil {
+		frontServer.h3 = &http3.Server{
+			Addr:      cfg.Addr,
+			TLSConfig: cfg.TLSConfig.Clone(),
+		}
 	}
 
 	handler := frontServer.initServerHandler()
@@ -58,6 +89,10 @@ func New(cfg Config) (*Server, error) {
 		ReadTimeout:  cfg.ReadTimeout,
 		WriteTimeout: cfg.WriteTimeout,
 		Handler:      handler,
+		TLSConfig:    cfg.TLSConfig,
+	}
+	if frontServer.h3 != nil {
+		frontServer.h3.Handler = handler
 	}
 
 	return frontServer, nil
@@ -77,17 +112,57 @@ func (s *Server) Run(ctx context.Context) error {
 		if err := s.srv.Shutdown(withTimeout); err != nil {
 			slog.Warn("err stopping http server", "err", err)
 		}
+		if s.h3 != nil {
+			if err := s.h3.Close(); err != nil {
+				slog.Warn("err stopping http3 server", "err", err)
+			}
+		}
 
 		slog.Info("web server gracefully stopped")
 		close(closedCh)
 	}()
 
-	slog.Info("starting http server on", "addr", s.srv.Addr)
-	if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		return err
+	if s.h3 == nil {
+		slog.Info("starting http server on", "addr", s.srv.Addr)
+		if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		<-closedCh
+		return nil
 	}
 
+	// HTTP/3 listens UDP on the same port and is advertised by Alt-Svc, if one of listeners fails, the other one is closed too.
+	eg := errgroup.Group{}
+	eg.Go(func() error {
+		slog.Info("starting https server on", "addr", s.srv.Addr)
+		if err := s.srv.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			_ = s.h3.Close()
+			return err
+		}
+		return nil
+	})
+	eg.Go(func() error {
+		slog.Info("starting http3 server on", "addr", s.h3.Addr)
+		if err := s.h3.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			_ = s.srv.Close()
+			return err
+		}
+		return nil
+	})
+	if err := eg.Wait(); err != nil {
+		return err
+	}
 	<-closedCh
 
 	return nil
 }
+
+// advertiseHTTP3 sets Alt-Svc header to TCP responses, so clients switch to HTTP/3.
+func (s *Server) advertiseHTTP3(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.ProtoMajor < 3 {
+			_ = s.h3.SetQUICHeaders(resp.Header())
+		}
+		next.ServeHTTP(resp, req)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
 	"io"
 	"log/slog"
@@ -14,6 +15,9 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
+
+	"github.com/itimofeev/yas3/internal/entity"
+	"github.com/itimofeev/yas3/internal/provider/auth"
 )
 
 func (s *Server) uploadFileHandler(resp http.ResponseWriter, req *http.Request) {
@@ -37,6 +41,20 @@ func (s *Server) uploadFileHandler(resp http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	maxSize, err := s.checkAccess(req, auth.OperationUpload, fileID.String())
+	if err != nil {
+		s.error(req, resp, err)
+		return
+	}
+	if maxSize > 0 {
+		// size limit embedded into presigned URL
+		if fileSize > maxSize {
+			writeErrResponse(resp, fmt.Sprintf("file size exceeds limit of presigned URL %d", maxSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		req.Body = http.MaxBytesReader(resp, req.Body, maxSize)
+	}
+
 	// check if file already uploaded with this id
 	if s.fileRegistry.IsFileExists(fileID.String()) {
 		s.error(req, resp, fmt.Errorf("file already exists %s", fileID.String()))
@@ -44,31 +62,56 @@ func (s *Server) uploadFileHandler(resp http.ResponseWriter, req *http.Request)
 	}
 
 	partSize := fileSize/s.cfg.PartsCount + 1
+	// content is read not faster than bytes rate of client
+	body := s.limiters.reader(req.Context(), clientID(req), req.Body)
 
-	// receives link to store servers where we can upload file parts
-	storeServers, err := s.serversRegistry.GetServersForParts(s.cfg.PartsCount)
+	// receives link to store servers where we can upload replicas of file parts
+	storeServers, err := s.serversRegistry.GetServersForParts(fileID.String(), s.cfg.PartsCount, s.cfg.ReplicationFactor)
+	if err != nil {
+		s.error(req, resp, err)
+		return
+	}
+
+	// parts are encrypted with data key of the file, so store servers only see ciphertext
+	cipher, encryptionMeta, err := s.newFileCipher(req, fileID.String())
 	if err != nil {
 		s.error(req, resp, err)
 		return
 	}
 
 	// upload file parts in cycle without any parallelism, because network to our store servers will be much faster than from client to rest server
-	serverIDs := make([]string, 0, len(storeServers))
-	for partNumber, storeForUpload := range storeServers {
-		fileName := fileID.String() + "." + strconv.FormatInt(int64(partNumber), 10)
-		partReader := io.LimitReader(req.Body, partSize)
-		err := storeForUpload.UploadFile(req.Context(), fileName, partReader)
+	// replicas of one part are uploaded at once
+	meta := entity.FileMeta{Parts: make([]entity.FilePart, 0, len(storeServers)), Encryption: encryptionMeta}
+	for partNumber, replicas := range storeServers {
+		fileName := entity.PartFileName(fileID.String(), partNumber)
+		partReader := io.LimitReader(body, partSize)
+		if cipher != nil {
+			partReader = cipher.EncryptReader(partNumber, partReader)
+		}
+		err := uploadPart(req.Context(), fileName, partReader, replicas)
 
 		if err != nil {
 			s.error(req, resp, err)
 			return
 		}
-		serverIDs = append(serverIDs, storeForUpload.GetID())
+		part := entity.FilePart{ServerIDs: make([]string, 0, len(replicas))}
+		for _, replica := range replicas {
+			part.ServerIDs = append(part.ServerIDs, replica.GetID())
+		}
+		meta.Parts = append(meta.Parts, part)
+	}
+	// body has to end with the last part, reading its end also verifies signed content hash
+	if n, err := io.Copy(io.Discard, io.LimitReader(body, 1)); err != nil || n > 0 {
+		if err == nil {
+			err = fmt.Errorf("file is larger than fileSize %d", fileSize)
+		}
+		s.error(req, resp, err)
+		return
 	}
 
 	// save information about uploaded file parts to internal db,
 	// current implementation is not very reliable, can discuss how to make it better
-	if err := s.fileRegistry.SaveFileParts(fileID.String(), serverIDs); err != nil {
+	if err := s.fileRegistry.SaveFile(fileID.String(), meta); err != nil {
 		s.error(req, resp, err)
 		return
 	}
@@ -82,33 +125,36 @@ func (s *Server) getFileHandler(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	serverIDs, err := s.fileRegistry.GetFileParts(fileID.String())
-	if err != nil {
+	if _, err := s.checkAccess(req, auth.OperationGet, fileID.String()); err != nil {
 		s.error(req, resp, err)
 		return
 	}
 
-	// get information about store servers on which file parts are stored
-	storeClients, err := s.serversRegistry.GetStoreClients(serverIDs)
+	meta, err := s.fileRegistry.GetFile(fileID.String())
+	if err != nil {
+		s.error(req, resp, err)
+		return
+	}
+	cipher, err := s.fileCipher(req, fileID.String(), meta)
 	if err != nil {
 		s.error(req, resp, err)
 		return
 	}
 
-	for partNumber, storeClient := range storeClients {
-		fileName := fileID.String() + "." + strconv.FormatInt(int64(partNumber), 10)
+	// content is written not faster than bytes rate of client
+	w := s.limiters.writer(req.Context(), clientID(req), resp)
+	for partNumber, part := range meta.Parts {
+		fileName := entity.PartFileName(fileID.String(), partNumber)
 
-		// copy part content from store server response directly to rest server response
-		err := func(ctx context.Context) error {
-			filePartReader, err := storeClient.GetFile(ctx, fileName)
-			if err != nil {
-				return err
-			}
-			defer filePartReader.Close()
+		// get information about online store servers on which replicas of file part are stored
+		replicas, err := s.serversRegistry.GetStoreClients(part.ServerIDs)
+		if err != nil {
+			s.error(req, resp, err)
+			return
+		}
 
-			_, err = io.Copy(resp, filePartReader)
-			return err
-		}(req.Context())
+		// copy part content from store server response directly to rest server response
+		err = copyPart(req.Context(), w, replicas, fileName, cipher, partNumber)
 
 		if err != nil {
 			s.error(req, resp, err)
@@ -122,6 +168,9 @@ func (s *Server) initServerHandler() *chi.Mux {
 	r.Use(
 		middleware.Recoverer,
 	)
+	if s.h3 != nil {
+		r.Use(s.advertiseHTTP3)
+	}
 
 	r.Group(func(router chi.Router) {
 		router.Group(func(telemetry chi.Router) {
@@ -141,15 +190,41 @@ func (s *Server) initServerHandler() *chi.Mux {
 			telemetry.Handle("/pprof/block", pprof.Handler("block"))
 			telemetry.Handle("/pprof/allocs", pprof.Handler("allocs"))
 		})
+		// metrics show store servers and repair state, so they are for admin only
+		if s.cfg.AccessKeys != nil {
+			router.With(s.limitRequests, s.authenticate, s.limitKeyRequests, s.requireAdmin).Handle("/debug/vars", expvar.Handler())
+		} else {
+			router.Handle("/debug/vars", expvar.Handler())
+		}
 	})
 
 	r.Group(func(r chi.Router) {
 		r.Use(middleware.RequestID)
 		r.Use(middleware.Logger)
 		r.Route("/api/v1", func(api chi.Router) {
-			api.Post("/uploadFile/{fileID}", s.uploadFileHandler)
-			api.Get("/getFile/{fileID}", s.getFileHandler)
+			api.Use(s.limitRequests)
+			// middlewares of routes run after routing, so presigned URL is verified for file id of the route
+			api.With(s.authenticateFileRequest, s.limitKeyRequests, s.limitStreams(streamUpload)).Post("/uploadFile/{fileID}", s.uploadFileHandler)
+			api.With(s.authenticateFileRequest, s.limitKeyRequests, s.limitStreams(streamDownload)).Get("/getFile/{fileID}", s.getFileHandler)
+			if s.cfg.PresignSecret != "" {
+				if s.cfg.AccessKeys != nil {
+					api.With(s.authenticate, s.limitKeyRequests).Post("/presign/{fileID}", s.presignHandler)
+				} else {
+					api.Post("/presign/{fileID}", s.presignHandler)
+				}
+			}
 		})
+		if s.cfg.AccessKeys != nil {
+			r.Route("/admin/v1", func(admin chi.Router) {
+				admin.Use(s.limitRequests, s.authenticate, s.limitKeyRequests, s.requireAdmin)
+				admin.Post("/keys", s.createKeyHandler)
+				admin.Get("/keys/{accessKey}/policy", s.getPolicyHandler)
+				admin.Put("/keys/{accessKey}/policy", s.putPolicyHandler)
+				admin.Post("/keys/{accessKey}/disable", s.setKeyDisabledHandler(true))
+				admin.Post("/keys/{accessKey}/enable", s.setKeyDisabledHandler(false))
+				admin.Delete("/keys/{accessKey}", s.deleteKeyHandler)
+			})
+		}
 	})
 
 	return r
@@ -158,9 +233,26 @@ func (s *Server) initServerHandler() *chi.Mux {
 func (s *Server) error(_ *http.Request, w http.ResponseWriter, err error) {
 	slog.Warn("got error while handling request", "err", err)
 
+	var (
+		maxBytesErr  *http.MaxBytesError
+		rateLimitErr *rateLimitError
+	)
 	switch {
 	case errors.Is(err, context.Canceled):
 		writeErrResponse(w, "timeout", http.StatusRequestTimeout)
+	case errors.Is(err, errUnauthenticated):
+		writeErrResponse(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, errForbidden):
+		writeErrResponse(w, err.Error(), http.StatusForbidden)
+	case errors.As(err, &rateLimitErr):
+		w.Header().Set("Retry-After", rateLimitErr.retryAfterHeader())
+		writeErrResponse(w, err.Error(), http.StatusTooManyRequests)
+	case errors.As(err, &maxBytesErr):
+		writeErrResponse(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, errInvalidCustomerKey):
+		writeErrResponse(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, errCustomerKeyMismatch):
+		writeErrResponse(w, err.Error(), http.StatusForbidden)
 	default:
 		writeErrResponse(w, err.Error(), http.StatusInternalServerError)
 	}
@@ -0,0 +1,42 @@
+package front
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/itimofeev/yas3/internal/entity"
+)
+
+// uploadPart uploads file part to all its replicas at once and fails if any replica fails.
+func uploadPart(ctx context.Context, fileName string, content io.Reader, replicas []entity.StoreClient) error {
+	if len(replicas) == 1 {
+		return replicas[0].UploadFile(ctx, fileName, content)
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	writers := make([]io.Writer, 0, len(replicas))
+	pipeWriters := make([]*io.PipeWriter, 0, len(replicas))
+	for _, replica := range replicas {
+		pipeReader, pipeWriter := io.Pipe()
+		writers = append(writers, pipeWriter)
+		pipeWriters = append(pipeWriters, pipeWriter)
+		eg.Go(func() error {
+			err := replica.UploadFile(ctx, fileName, pipeReader)
+			// unblock writing to other replicas if this one failed before reading the whole content
+			_ = pipeReader.CloseWithError(err)
+			return err
+		})
+	}
+
+	_, copyErr := io.Copy(io.MultiWriter(writers...), content)
+	for _, pipeWriter := range pipeWriters {
+		_ = pipeWriter.CloseWithError(copyErr)
+	}
+
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	return copyErr
+}
@@ -0,0 +1,213 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	CompressionNone = "none"
+	CompressionZstd = "zstd"
+	CompressionS2   = "s2"
+)
+
+// compressionFrameSize is size of uncompressed data in one independently compressed frame.
+const compressionFrameSize = 256 * 1024
+
+// codec compresses and decompresses one frame.
+type codec interface {
+	encode(dst, src []byte) []byte
+	decode(dst, src []byte) ([]byte, error)
+}
+
+// newCodec returns codec by name, nil codec means that files are stored as is.
+func newCodec(name string) (codec, error) {
+	switch name {
+	case "", CompressionNone:
+		return nil, nil
+	case CompressionZstd:
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		decoder, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		return &zstdCodec{encoder: encoder, decoder: decoder}, nil
+	case CompressionS2:
+		return s2Codec{}, nil
+	default:
+		return nil, fmt.Errorf("unknown compression %s", name)
+	}
+}
+
+type zstdCodec struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func (c *zstdCodec) encode(dst, src []byte) []byte {
+	return c.encoder.EncodeAll(src, dst[:0])
+}
+
+func (c *zstdCodec) decode(dst, src []byte) ([]byte, error) {
+	return c.decoder.DecodeAll(src, dst[:0])
+}
+
+type s2Codec struct{}
+
+func (s2Codec) encode(dst, src []byte) []byte {
+	return s2.Encode(dst[:cap(dst)], src)
+}
+
+func (s2Codec) decode(dst, src []byte) ([]byte, error) {
+	return s2.Decode(dst[:cap(dst)], src)
+}
+
+// frameWriter compresses data by frames and remembers compressed size of every frame.
+type frameWriter struct {
+	w      io.Writer
+	codec  codec
+	buf    []byte
+	out    []byte
+	frames []int64
+	size   int64
+}
+
+func newFrameWriter(w io.Writer, codec codec) *frameWriter {
+	return &frameWriter{
+		w:     w,
+		codec: codec,
+		buf:   make([]byte, 0, compressionFrameSize),
+	}
+}
+
+func (w *frameWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), compressionFrameSize-len(w.buf))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(w.buf) == compressionFrameSize {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close writes the last incomplete frame.
+func (w *frameWriter) Close() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	return w.flush()
+}
+
+func (w *frameWriter) flush() error {
+	w.out = w.codec.encode(w.out, w.buf)
+	if _, err := w.w.Write(w.out); err != nil {
+		return err
+	}
+	w.frames = append(w.frames, int64(len(w.out)))
+	w.size += int64(len(w.buf))
+	w.buf = w.buf[:0]
+	return nil
+}
+
+var errCorruptedFrames = errors.New("compressed frames don't match file size")
+
+// frameReader reads uncompressed content of file written by frameWriter, seek decompresses only frames it reads.
+type frameReader struct {
+	r       io.ReaderAt
+	codec   codec
+	frames  []int64
+	offsets []int64
+	size    int64
+
+	pos     int64
+	current int
+	buf     []byte
+	in      []byte
+}
+
+func newFrameReader(r io.ReaderAt, codec codec, frames []int64, size int64) *frameReader {
+	offsets := make([]int64, len(frames))
+	var offset int64
+	for i, frameSize := range frames {
+		offsets[i] = offset
+		offset += frameSize
+	}
+	return &frameReader{
+		r:       r,
+		codec:   codec,
+		frames:  frames,
+		offsets: offsets,
+		size:    size,
+		current: -1,
+	}
+}
+
+func (r *frameReader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+
+	frame := int(r.pos / compressionFrameSize)
+	if frame >= len(r.frames) {
+		return 0, errCorruptedFrames
+	}
+	if frame != r.current {
+		if err := r.load(frame); err != nil {
+			return 0, err
+		}
+	}
+
+	frameOffset := int(r.pos - int64(frame)*compressionFrameSize)
+	if frameOffset >= len(r.buf) {
+		return 0, errCorruptedFrames
+	}
+	n := copy(p, r.buf[frameOffset:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+func (r *frameReader) load(frame int) error {
+	r.current = -1
+	r.in = append(r.in[:0], make([]byte, r.frames[frame])...)
+	if _, err := r.r.ReadAt(r.in, r.offsets[frame]); err != nil {
+		return err
+	}
+
+	var err error
+	r.buf, err = r.codec.decode(r.buf, r.in)
+	if err != nil {
+		return err
+	}
+	r.current = frame
+	return nil
+}
+
+func (r *frameReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
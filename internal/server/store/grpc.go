@@ -0,0 +1,220 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/itimofeev/yas3/api/storepb"
+	"github.com/itimofeev/yas3/internal/provider/auth"
+)
+
+// grpcChunkSize is size of data in every streamed message of file content.
+const grpcChunkSize = 64 * 1024
+
+// grpcServer serves the same storage as HTTP/3 API through gRPC streams.
+type grpcServer struct {
+	storepb.UnimplementedStoreServer
+	s *Server
+}
+
+func (s *Server) newGRPCServer() *grpc.Server {
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(s.cfg.TLSConfig)))
+	storepb.RegisterStoreServer(srv, &grpcServer{s: s})
+	return srv
+}
+
+func (g *grpcServer) UploadFile(stream storepb.Store_UploadFileServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return grpcError(err)
+	}
+	fileName := first.GetFileName()
+	if err := g.s.verifyGRPCToken(stream.Context(), auth.StoreOperationUpload, fileName); err != nil {
+		return grpcError(err)
+	}
+	if g.s.readOnly.Load() {
+		return grpcError(errReadOnly)
+	}
+
+	content := &uploadStreamReader{stream: stream, buf: first.GetData()}
+	if err := g.s.writeFile(fileName, content); err != nil {
+		return grpcError(err)
+	}
+	return stream.SendAndClose(&storepb.UploadFileResponse{})
+}
+
+// uploadStreamReader reads file content from messages of upload stream.
+type uploadStreamReader struct {
+	stream storepb.Store_UploadFileServer
+	buf    []byte
+}
+
+func (r *uploadStreamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		msg, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = msg.GetData()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (g *grpcServer) GetFile(req *storepb.GetFileRequest, stream storepb.Store_GetFileServer) error {
+	if err := g.s.verifyGRPCToken(stream.Context(), auth.StoreOperationGet, req.GetFileName()); err != nil {
+		return grpcError(err)
+	}
+	file, err := g.s.openStoredFile(req.GetFileName())
+	if err != nil {
+		return grpcError(err)
+	}
+	defer file.Close()
+
+	if req.GetIfRange() != "" && req.GetIfRange() != file.etag() {
+		return status.Errorf(codes.FailedPrecondition, "file etag %s doesn't match %s", file.etag(), req.GetIfRange())
+	}
+	if req.GetOffset() < 0 || req.GetOffset() > file.size {
+		return status.Errorf(codes.OutOfRange, "offset %d is out of file size %d", req.GetOffset(), file.size)
+	}
+	if _, err := file.Seek(req.GetOffset(), io.SeekStart); err != nil {
+		return grpcError(err)
+	}
+	var content io.Reader = file
+	if req.GetLength() >= 0 {
+		content = io.LimitReader(file, req.GetLength())
+	}
+
+	buf := make([]byte, grpcChunkSize)
+	for first := true; ; first = false {
+		n, err := io.ReadFull(content, buf)
+		if n > 0 || first {
+			msg := &storepb.GetFileResponse{Data: buf[:n]}
+			if first {
+				msg.Size, msg.Etag = file.size, file.etag()
+			}
+			if sendErr := stream.Send(msg); sendErr != nil {
+				return sendErr
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return grpcError(err)
+		}
+	}
+}
+
+func (g *grpcServer) DeleteFile(ctx context.Context, req *storepb.DeleteFileRequest) (*storepb.DeleteFileResponse, error) {
+	if err := g.s.verifyGRPCToken(ctx, auth.StoreOperationDelete, req.GetFileName()); err != nil {
+		return nil, grpcError(err)
+	}
+	if err := g.s.removeFile(req.GetFileName()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &storepb.DeleteFileResponse{}, nil
+}
+
+func (g *grpcServer) StatFile(ctx context.Context, req *storepb.StatFileRequest) (*storepb.StatFileResponse, error) {
+	if err := g.s.verifyGRPCToken(ctx, auth.StoreOperationStat, req.GetFileName()); err != nil {
+		return nil, grpcError(err)
+	}
+	info, err := g.s.statStoredFile(req.GetFileName())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &storepb.StatFileResponse{
+		Size:            info.Size,
+		ModTimeUnixNano: info.ModTime.UnixNano(),
+		Checksum:        info.Checksum,
+	}, nil
+}
+
+func (g *grpcServer) ListFiles(ctx context.Context, req *storepb.ListFilesRequest) (*storepb.ListFilesResponse, error) {
+	if err := g.s.verifyGRPCToken(ctx, auth.StoreOperationList, ""); err != nil {
+		return nil, grpcError(err)
+	}
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = defaultListLimit
+	}
+	if limit < 0 || limit > maxListLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid limit %d", limit)
+	}
+
+	names, err := g.s.listStoredFiles(req.GetAfter(), limit)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &storepb.ListFilesResponse{Names: names}, nil
+}
+
+func (g *grpcServer) GetStats(ctx context.Context, _ *storepb.GetStatsRequest) (*storepb.GetStatsResponse, error) {
+	if err := g.s.verifyGRPCToken(ctx, auth.StoreOperationStats, ""); err != nil {
+		return nil, grpcError(err)
+	}
+	stats := g.s.usage.stats(g.s.cfg.BasePath, int64(g.s.cfg.MaxAvailableSpaceBytes))
+
+	return &storepb.GetStatsResponse{
+		Total:          stats.Total,
+		Capacity:       stats.Capacity,
+		Used:           stats.Used,
+		LogicalUsed:    stats.LogicalUsed,
+		FilesCount:     stats.FilesCount,
+		InodesTotal:    stats.InodesTotal,
+		InodesFree:     stats.InodesFree,
+		ReadOnly:       g.s.readOnly.Load(),
+		Zone:           g.s.cfg.Zone,
+		Rack:           g.s.cfg.Rack,
+		Host:           g.s.cfg.Host,
+		CorruptedFiles: g.s.corrupted.list(),
+	}, nil
+}
+
+// verifyGRPCToken checks bearer token from request metadata the same way as requireToken does for HTTP/3 requests.
+func (s *Server) verifyGRPCToken(ctx context.Context, operation, fileName string) error {
+	if s.cfg.TokenSecret == "" {
+		return nil
+	}
+	var token string
+	if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) > 0 {
+		token, _ = strings.CutPrefix(values[0], "Bearer ")
+	}
+	return auth.VerifyStoreToken(s.cfg.TokenSecret, token, s.cfg.TokenAudiences, operation, fileName, time.Now())
+}
+
+// grpcError converts storage error to gRPC status, it mirrors HTTP status codes of error method.
+func grpcError(err error) error {
+	slog.Warn("got error while handling grpc request", "err", err)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, auth.ErrInvalidStoreToken):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, errReadOnly):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, errInvalidFileName):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, fs.ErrNotExist):
+		return status.Error(codes.NotFound, "file not found")
+	case errors.Is(err, fs.ErrExist):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, errQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// metaDirName is directory inside base path with sidecar metadata of stored files, it mirrors sharded layout of files.
+const metaDirName = ".meta"
+
+// fileMeta is sidecar metadata written next to every file at upload time.
+type fileMeta struct {
+	// Size is size of uploaded content, StoredSize is size on disk after compression.
+	Size       int64 `json:"size"`
+	StoredSize int64 `json:"storedSize"`
+	// Checksum is hex encoded sha256 of uploaded content.
+	Checksum string `json:"checksum"`
+	// Codec is compression of the file, compressed files also have sizes of compressed frames.
+	Codec  string  `json:"codec"`
+	Frames []int64 `json:"frames,omitempty"`
+}
+
+func (m fileMeta) isCompressed() bool {
+	return m.Codec != "" && m.Codec != CompressionNone
+}
+
+func (s *Server) metaPath(fileName string) string {
+	rel, _ := filepath.Rel(s.cfg.BasePath, s.filePath(fileName))
+	return filepath.Join(s.cfg.BasePath, metaDirName, rel+".json")
+}
+
+// readMeta returns sidecar metadata of file, fs.ErrNotExist is returned for files uploaded before sidecars were introduced.
+func (s *Server) readMeta(fileName string) (fileMeta, error) {
+	data, err := os.ReadFile(s.metaPath(fileName))
+	if err != nil {
+		return fileMeta{}, err
+	}
+
+	var meta fileMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return fileMeta{}, err
+	}
+	return meta, nil
+}
+
+// writeMeta atomically writes sidecar metadata of file through temp file, existing sidecar is never replaced.
+func (s *Server) writeMeta(fileName string, meta fileMeta) error {
+	tmpPath, err := s.writeMetaTemp(fileName, meta)
+	if err != nil {
+		return err
+	}
+	return s.installMeta(fileName, tmpPath)
+}
+
+// writeMetaTemp writes sidecar metadata of file to temp file reserving its space, it is moved into place by installMeta.
+func (s *Server) writeMetaTemp(fileName string, meta fileMeta) (string, error) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return "", err
+	}
+
+	tmpFile, err := os.CreateTemp(s.tempDir(), fileName+".meta.*")
+	if err != nil {
+		return "", err
+	}
+	defer tmpFile.Close()
+
+	writer := &quotaWriter{w: tmpFile, usage: &s.usage, quota: int64(s.cfg.MaxAvailableSpaceBytes)}
+	_, err = writer.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if err == nil {
+		err = tmpFile.Close()
+	}
+	if err != nil {
+		s.usage.add(-writer.written, 0)
+		_ = os.Remove(tmpFile.Name())
+		return "", err
+	}
+	return tmpFile.Name(), nil
+}
+
+// installMeta links sidecar temp file into place and releases its space on failure, fs.ErrExist is returned if sidecar exists.
+func (s *Server) installMeta(fileName, tmpPath string) error {
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	info, err := os.Stat(tmpPath)
+	if err != nil {
+		return err
+	}
+	metaPath := s.metaPath(fileName)
+	err = s.makeDir(filepath.Dir(metaPath))
+	if err == nil {
+		err = os.Link(tmpPath, metaPath)
+	}
+	if err != nil {
+		s.usage.add(-info.Size(), 0)
+	}
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("sidecar of file %s: %w", fileName, fs.ErrExist)
+	}
+	if err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(metaPath))
+}
+
+// storedFile gives uncompressed content of stored file.
+type storedFile struct {
+	io.ReadSeeker
+	file    *os.File
+	meta    fileMeta
+	hasMeta bool
+	size    int64
+	modTime time.Time
+}
+
+func (f *storedFile) Close() error {
+	return f.file.Close()
+}
+
+// etag identifies content of file: it is checksum from sidecar, or size and modification time for files without sidecar.
+func (f *storedFile) etag() string {
+	if f.hasMeta {
+		return `"` + f.meta.Checksum + `"`
+	}
+	return fmt.Sprintf(`"%x-%x"`, f.size, f.modTime.UnixNano())
+}
+
+// openStoredFile opens stored file for reading, compressed files are decompressed frame by frame while reading.
+func (s *Server) openStoredFile(fileName string) (*storedFile, error) {
+	if err := validateFileName(fileName); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(s.filePath(fileName))
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	meta, err := s.readMeta(fileName)
+	hasMeta := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		_ = file.Close()
+		return nil, err
+	}
+
+	f := &storedFile{
+		ReadSeeker: file,
+		file:       file,
+		meta:       meta,
+		hasMeta:    hasMeta,
+		size:       info.Size(),
+		modTime:    info.ModTime(),
+	}
+	if hasMeta && meta.isCompressed() {
+		codec, ok := s.codecs[meta.Codec]
+		if !ok {
+			_ = file.Close()
+			return nil, fmt.Errorf("unknown codec %s of file %s", meta.Codec, fileName)
+		}
+		f.ReadSeeker = newFrameReader(file, codec, meta.Frames, meta.Size)
+		f.size = meta.Size
+	}
+	return f, nil
+}
+
+// sweepOrphanMeta removes sidecars left without files by previous server stop, they would block upload of the same file.
+func (s *Server) sweepOrphanMeta() error {
+	metaDir := filepath.Join(s.cfg.BasePath, metaDirName)
+	var removed int
+	err := filepath.WalkDir(metaDir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == metaDir {
+			return nil
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		fileName, ok := strings.CutSuffix(d.Name(), ".json")
+		if !ok {
+			return nil
+		}
+		if _, err := os.Stat(s.filePath(fileName)); !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if removed > 0 {
+		slog.Info("orphan sidecars removed", "count", removed)
+	}
+	return err
+}
+
+func (s *Server) removeMeta(fileName string) error {
+	size, err := s.removeSized(s.metaPath(fileName), fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.usage.add(-size, 0)
+	return nil
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// RunScrubber periodically re-hashes stored files with limited rate and reports files not matching checksums from sidecars.
+func (s *Server) RunScrubber(ctx context.Context) error {
+	if s.cfg.ScrubRateBytes <= 0 {
+		slog.Info("scrubber is disabled")
+		return nil
+	}
+
+	for {
+		if err := s.scrub(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("scrubber pass failed", "err", err)
+		}
+
+		select {
+		case <-time.After(s.cfg.ScrubInterval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (s *Server) scrub(ctx context.Context) error {
+	slog.Info("scrubber pass started")
+	pacer := &scrubPacer{rate: s.cfg.ScrubRateBytes, start: time.Now()}
+
+	var checked int
+	err := filepath.WalkDir(s.cfg.BasePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if isServiceDir(s.cfg.BasePath, path, d) {
+			return filepath.SkipDir
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if err := s.scrubFile(ctx, d.Name(), pacer); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			slog.Warn("failed to scrub file", "fileName", d.Name(), "err", err)
+		}
+		checked++
+		return nil
+	})
+
+	slog.Info("scrubber pass finished", "checked", checked, "corrupted", len(s.corrupted.list()), "duration", time.Since(pacer.start))
+	return err
+}
+
+// scrubFile compares checksum of file content with checksum from sidecar, file without sidecar gets one.
+func (s *Server) scrubFile(ctx context.Context, fileName string, pacer *scrubPacer) error {
+	file, err := s.openStoredFile(fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		// deleted while scrubbing
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, &pacedReader{ctx: ctx, r: file, pacer: pacer})
+	if err != nil && ctx.Err() == nil && file.meta.isCompressed() {
+		// compressed frames can't be decoded
+		slog.Error("corrupted file found", "fileName", fileName, "err", err)
+		s.corrupted.add(fileName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	meta := file.meta
+	if !file.hasMeta {
+		return s.addMissingMeta(fileName, file, fileMeta{Size: size, StoredSize: size, Checksum: checksum, Codec: CompressionNone})
+	}
+	if size != meta.Size || checksum != meta.Checksum {
+		slog.Error("corrupted file found", "fileName", fileName, "size", size, "expectedSize", meta.Size)
+		s.corrupted.add(fileName)
+		return nil
+	}
+	s.corrupted.remove(fileName)
+	return nil
+}
+
+// addMissingMeta writes sidecar of file without one, existing sidecar is kept and added one is removed if file was deleted.
+func (s *Server) addMissingMeta(fileName string, file *storedFile, meta fileMeta) error {
+	err := s.writeMeta(fileName, meta)
+	if errors.Is(err, fs.ErrExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	opened, err := file.file.Stat()
+	if err != nil {
+		return err
+	}
+	current, err := os.Stat(s.filePath(fileName))
+	if err == nil && os.SameFile(opened, current) {
+		return nil
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return s.removeMeta(fileName)
+}
+
+// scrubPacer limits read rate of scrubber, so it doesn't compete with uploads and downloads for disk.
+type scrubPacer struct {
+	rate  int64
+	start time.Time
+	read  int64
+}
+
+func (p *scrubPacer) wait(ctx context.Context, n int) error {
+	p.read += int64(n)
+	expected := time.Duration(float64(p.read) / float64(p.rate) * float64(time.Second))
+	sleep := expected - time.Since(p.start)
+	if sleep <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(sleep)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type pacedReader struct {
+	ctx   context.Context //nolint:containedctx // reader is used only inside one scrubFile call
+	r     io.Reader
+	pacer *scrubPacer
+}
+
+func (r *pacedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if waitErr := r.pacer.wait(r.ctx, n); waitErr != nil {
+		return n, waitErr
+	}
+	return n, err
+}
+
+// corruptedFiles is set of stored files which content doesn't match checksum from sidecar.
+type corruptedFiles struct {
+	mu    sync.Mutex
+	names map[string]struct{}
+}
+
+func (c *corruptedFiles) add(fileName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.names == nil {
+		c.names = make(map[string]struct{})
+	}
+	c.names[fileName] = struct{}{}
+}
+
+func (c *corruptedFiles) remove(fileName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.names, fileName)
+}
+
+func (c *corruptedFiles) list() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.names))
+	for name := range c.names {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// spaceUsage tracks bytes on disk, uploaded bytes before compression and number of stored files incrementally.
+type spaceUsage struct {
+	usedBytes    atomic.Int64
+	logicalBytes atomic.Int64
+	filesCount   atomic.Int64
+}
+
+// diskStats is combination of tracked usage, configured quota and file system statistics.
+type diskStats struct {
+	Total       int64
+	Capacity    int64
+	Used        int64
+	LogicalUsed int64
+	FilesCount  int64
+	InodesTotal int64
+	InodesFree  int64
+}
+
+// scan rebuilds usage counters by walking base path at startup, sidecars are counted in used bytes only.
+func (u *spaceUsage) scan(basePath string, logicalSize func(fileName string, storedSize int64) int64) error {
+	var usedBytes, logicalBytes, filesCount int64
+	metaDir := filepath.Join(basePath, metaDirName)
+	err := filepath.WalkDir(basePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if isServiceDir(basePath, path, d) && path != metaDir {
+			return filepath.SkipDir
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		usedBytes += info.Size()
+		if strings.HasPrefix(path, metaDir+string(filepath.Separator)) {
+			return nil
+		}
+		logicalBytes += logicalSize(d.Name(), info.Size())
+		filesCount++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	u.usedBytes.Store(usedBytes)
+	u.logicalBytes.Store(logicalBytes)
+	u.filesCount.Store(filesCount)
+	slog.Info("store space usage scanned", "usedBytes", usedBytes, "logicalBytes", logicalBytes, "filesCount", filesCount)
+	return nil
+}
+
+func (u *spaceUsage) add(bytes, files int64) {
+	u.usedBytes.Add(bytes)
+	u.filesCount.Add(files)
+}
+
+func (u *spaceUsage) addLogical(bytes int64) {
+	u.logicalBytes.Add(bytes)
+}
+
+// stats returns disk statistics, total space is the smaller of quota and space physically available on file system.
+func (u *spaceUsage) stats(basePath string, quota int64) diskStats {
+	stats := diskStats{
+		Total:       quota,
+		Capacity:    quota,
+		Used:        u.usedBytes.Load(),
+		LogicalUsed: u.logicalBytes.Load(),
+		FilesCount:  u.filesCount.Load(),
+	}
+
+	fsStats, err := statFS(basePath)
+	if err != nil {
+		slog.Warn("failed to get file system statistics", "err", err)
+		return stats
+	}
+
+	stats.Total = stats.Used + max(0, min(quota-stats.Used, fsStats.freeBytes))
+	stats.InodesTotal = fsStats.inodesTotal
+	stats.InodesFree = fsStats.inodesFree
+	return stats
+}
+
+type fsStats struct {
+	freeBytes   int64
+	inodesTotal int64
+	inodesFree  int64
+}
+
+var errQuotaExceeded = errors.New("store server quota exceeded")
+
+// quotaWriter reserves space in usage counters before every write and fails when total used space exceeds quota.
+type quotaWriter struct {
+	w       io.Writer
+	usage   *spaceUsage
+	quota   int64
+	written int64
+}
+
+func (w *quotaWriter) Write(p []byte) (int, error) {
+	if w.usage.usedBytes.Add(int64(len(p))) > w.quota {
+		w.usage.usedBytes.Add(-int64(len(p)))
+		return 0, errQuotaExceeded
+	}
+
+	n, err := w.w.Write(p)
+	w.usage.usedBytes.Add(int64(n - len(p)))
+	w.written += int64(n)
+	return n, err
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package store
+
+import (
+	"errors"
+)
+
+func statFS(string) (fsStats, error) {
+	return fsStats{}, errors.New("file system statistics are not supported on this platform")
+}
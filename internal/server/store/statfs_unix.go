@@ -0,0 +1,20 @@
+//go:build linux || darwin
+
+package store
+
+import (
+	"syscall"
+)
+
+func statFS(path string) (fsStats, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return fsStats{}, err
+	}
+
+	return fsStats{
+		freeBytes:   int64(st.Bavail) * int64(st.Bsize),
+		inodesTotal: int64(st.Files),
+		inodesFree:  int64(st.Ffree),
+	}, nil
+}
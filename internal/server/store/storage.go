@@ -0,0 +1,425 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// tempDirName is directory inside base path for files which are being uploaded.
+	tempDirName = ".tmp"
+	// migrateDirName is directory inside base path for files of flat layout which names clash with shard directories.
+	migrateDirName = ".migrate"
+)
+
+var errInvalidFileName = errors.New("invalid file name")
+
+// fileNameRe allows only names that can't escape base path and can't clash with service directories starting with dot.
+var fileNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,254}$`)
+
+// shardDirRe matches names of shard directories in base path.
+var shardDirRe = regexp.MustCompile(`^[0-9a-f]{2}$`)
+
+func validateFileName(fileName string) error {
+	if !fileNameRe.MatchString(fileName) {
+		return fmt.Errorf("%w: %q", errInvalidFileName, fileName)
+	}
+	return nil
+}
+
+// filePath returns path of file in sharded layout by hash prefix of file name, for example base/3f/a2/fileName.
+func (s *Server) filePath(fileName string) string {
+	sum := sha256.Sum256([]byte(fileName))
+	prefix := hex.EncodeToString(sum[:2])
+	return filepath.Join(s.cfg.BasePath, prefix[:2], prefix[2:], fileName)
+}
+
+// migrateFlatLayout moves files stored directly in base path by previous versions into sharded layout, existing files are not replaced.
+func (s *Server) migrateFlatLayout() error {
+	entries, err := os.ReadDir(s.cfg.BasePath)
+	if err != nil {
+		return err
+	}
+	migrateDir := filepath.Join(s.cfg.BasePath, migrateDirName)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !shardDirRe.MatchString(entry.Name()) {
+			continue
+		}
+		if err := os.MkdirAll(migrateDir, os.ModePerm); err != nil {
+			return err
+		}
+		if err := moveFile(filepath.Join(s.cfg.BasePath, entry.Name()), filepath.Join(migrateDir, entry.Name())); err != nil {
+			return fmt.Errorf("move aside file %s clashing with shard directory: %w", entry.Name(), err)
+		}
+	}
+
+	var migrated int
+	for _, dir := range []string{migrateDir, s.cfg.BasePath} {
+		names, err := s.flatFiles(dir)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			filePath := s.filePath(name)
+			if err := s.makeDir(filepath.Dir(filePath)); err != nil {
+				return err
+			}
+			err := moveFile(filepath.Join(dir, name), filePath)
+			if errors.Is(err, fs.ErrExist) {
+				slog.Warn("skip migration of file which already exists in sharded layout", "fileName", name, "dir", dir)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			migrated++
+		}
+	}
+	// migrate directory is removed only when all its files are migrated
+	_ = os.Remove(migrateDir)
+	if migrated > 0 {
+		slog.Info("files migrated to sharded layout", "count", migrated)
+		return syncDir(s.cfg.BasePath)
+	}
+	return nil
+}
+
+// flatFiles returns names of regular files with valid names directly in dir.
+func (s *Server) flatFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if err := validateFileName(entry.Name()); err != nil {
+			slog.Warn("skip migration of file with invalid name", "fileName", entry.Name())
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// moveFile moves file by link, so existing destination is never replaced and fs.ErrExist is returned instead.
+func moveFile(src, dst string) error {
+	if err := os.Link(src, dst); err != nil {
+		if !errors.Is(err, fs.ErrExist) || !sameFile(src, dst) {
+			return err
+		}
+	}
+	return os.Remove(src)
+}
+
+func sameFile(a, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bInfo, err := os.Stat(b)
+	return err == nil && os.SameFile(aInfo, bInfo)
+}
+
+// makeDir creates shard directory if it doesn't exist and syncs its parents, so new directory is not lost after crash.
+func (s *Server) makeDir(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	for parent := filepath.Dir(dir); ; parent = filepath.Dir(parent) {
+		if err := syncDir(parent); err != nil {
+			return err
+		}
+		if parent == filepath.Clean(s.cfg.BasePath) || parent == filepath.Dir(parent) {
+			return nil
+		}
+	}
+}
+
+func (s *Server) tempDir() string {
+	return filepath.Join(s.cfg.BasePath, tempDirName)
+}
+
+// sweepTempFiles removes files left by uploads interrupted by previous server stop.
+func (s *Server) sweepTempFiles() error {
+	entries, err := os.ReadDir(s.tempDir())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(s.tempDir(), entry.Name())); err != nil {
+			return err
+		}
+	}
+	if len(entries) > 0 {
+		slog.Info("stale temp files removed", "count", len(entries))
+	}
+	return os.MkdirAll(s.tempDir(), os.ModePerm)
+}
+
+// writeFile writes content to temp file and links it into place after its sidecar, so file is never seen truncated or without sidecar.
+func (s *Server) writeFile(fileName string, content io.Reader) error {
+	if err := validateFileName(fileName); err != nil {
+		return err
+	}
+
+	// existing file is rejected before reading content, concurrent uploads are rejected by link below
+	filePath := s.filePath(fileName)
+	if _, err := os.Stat(filePath); err == nil {
+		return fmt.Errorf("file %s: %w", fileName, fs.ErrExist)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(s.tempDir(), fileName+".*")
+	if err != nil {
+		return err
+	}
+	defer tmpFile.Close()
+
+	writer := &quotaWriter{w: tmpFile, usage: &s.usage, quota: int64(s.cfg.MaxAvailableSpaceBytes)}
+	var (
+		dataWriter io.Writer = writer
+		frames     *frameWriter
+	)
+	if codec := s.codecs[s.cfg.Compression]; codec != nil {
+		frames = newFrameWriter(writer, codec)
+		dataWriter = frames
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(dataWriter, hash), content)
+	meta := fileMeta{Size: size, Codec: CompressionNone}
+	if err == nil && frames != nil {
+		err = frames.Close()
+		meta.Codec, meta.Frames = s.cfg.Compression, frames.frames
+	}
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if err == nil {
+		err = tmpFile.Close()
+	}
+	var metaTmpPath string
+	if err == nil {
+		meta.StoredSize, meta.Checksum = writer.written, hex.EncodeToString(hash.Sum(nil))
+		metaTmpPath, err = s.writeMetaTemp(fileName, meta)
+	}
+	if err == nil {
+		err = s.installMeta(fileName, metaTmpPath)
+		if errors.Is(err, fs.ErrExist) {
+			err = fmt.Errorf("file %s: %w", fileName, fs.ErrExist)
+		}
+	}
+	metaInstalled, linked := err == nil, false
+	if err == nil {
+		err = s.makeDir(filepath.Dir(filePath))
+	}
+	if err == nil {
+		err = os.Link(tmpFile.Name(), filePath)
+		if errors.Is(err, fs.ErrExist) {
+			err = fmt.Errorf("file %s: %w", fileName, fs.ErrExist)
+		}
+		linked = err == nil
+	}
+	if removeErr := os.Remove(tmpFile.Name()); removeErr != nil {
+		slog.Warn("failed to remove temp file", "fileName", fileName, "err", removeErr)
+	}
+	if err == nil {
+		err = syncDir(filepath.Dir(filePath))
+	}
+	if err != nil {
+		if linked {
+			s.removeLinked(fileName)
+		} else if metaInstalled {
+			if err := s.removeMeta(fileName); err != nil {
+				slog.Warn("failed to remove sidecar of failed upload", "fileName", fileName, "err", err)
+			}
+		}
+		s.usage.add(-writer.written, 0)
+		return err
+	}
+	s.usage.add(0, 1)
+	s.usage.addLogical(size)
+	s.corrupted.remove(fileName)
+	return nil
+}
+
+// removeLinked removes file and sidecar of failed upload after file was linked into place.
+func (s *Server) removeLinked(fileName string) {
+	if err := os.Remove(s.filePath(fileName)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("failed to remove file of failed upload", "fileName", fileName, "err", err)
+	}
+	if err := s.removeMeta(fileName); err != nil {
+		slog.Warn("failed to remove sidecar of failed upload", "fileName", fileName, "err", err)
+	}
+}
+
+// isServiceDir returns true for directories inside base path that don't contain stored files, like temp or meta ones.
+func isServiceDir(basePath, path string, d fs.DirEntry) bool {
+	return d.IsDir() && path != basePath && strings.HasPrefix(d.Name(), ".")
+}
+
+// syncDir flushes directory entries, so renamed file is not lost after crash.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
+
+type fileInfo struct {
+	Size     int64
+	ModTime  time.Time
+	Checksum string
+}
+
+// statStoredFile returns size, modification time and sha256 checksum of stored file content.
+func (s *Server) statStoredFile(fileName string) (fileInfo, error) {
+	file, err := s.openStoredFile(fileName)
+	if err != nil {
+		return fileInfo{}, err
+	}
+	defer file.Close()
+
+	info := fileInfo{
+		Size:     file.size,
+		ModTime:  file.modTime,
+		Checksum: file.meta.Checksum,
+	}
+	if file.hasMeta {
+		return info, nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fileInfo{}, err
+	}
+	info.Checksum = hex.EncodeToString(hash.Sum(nil))
+	return info, nil
+}
+
+// removeFile removes stored file and releases its space.
+func (s *Server) removeFile(fileName string) error {
+	if err := validateFileName(fileName); err != nil {
+		return err
+	}
+
+	filePath := s.filePath(fileName)
+	size, err := s.removeSized(filePath, fileName)
+	if err != nil {
+		return err
+	}
+	// sidecar is removed after file, so it still describes removed file
+	logicalSize := size
+	if meta, err := s.readMeta(fileName); err == nil {
+		logicalSize = meta.Size
+	}
+	s.usage.add(-size, -1)
+	s.usage.addLogical(-logicalSize)
+	s.corrupted.remove(fileName)
+	if err := s.removeMeta(fileName); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(filePath))
+}
+
+// removeSized removes file after moving it to temp dir and returns its size, so only one of concurrent removals gets it.
+func (s *Server) removeSized(path, fileName string) (int64, error) {
+	tmpFile, err := os.CreateTemp(s.tempDir(), fileName+".removed.*")
+	if err != nil {
+		return 0, err
+	}
+	_ = tmpFile.Close()
+	defer func() { _ = os.Remove(tmpFile.Name()) }()
+
+	if err := os.Rename(path, tmpFile.Name()); err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(tmpFile.Name())
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// listStoredFiles returns up to limit names of stored files which follow after in order of shard directory and name.
+func (s *Server) listStoredFiles(after string, limit int) ([]string, error) {
+	var afterShard string
+	if after != "" {
+		afterShard, _ = filepath.Rel(s.cfg.BasePath, filepath.Dir(s.filePath(after)))
+	}
+
+	names := make([]string, 0, limit)
+	topDirs, err := shardDirs(s.cfg.BasePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, top := range topDirs {
+		if after != "" && top < filepath.Dir(afterShard) {
+			continue
+		}
+		dirs, err := shardDirs(filepath.Join(s.cfg.BasePath, top))
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			shard := filepath.Join(top, dir)
+			if shard < afterShard {
+				continue
+			}
+			entries, err := os.ReadDir(filepath.Join(s.cfg.BasePath, shard))
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				if !entry.Type().IsRegular() || shard == afterShard && entry.Name() <= after {
+					continue
+				}
+				names = append(names, entry.Name())
+				if len(names) == limit {
+					return names, nil
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+// shardDirs returns sorted names of shard directories in dir.
+func shardDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && shardDirRe.MatchString(entry.Name()) {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	return dirs, nil
+}
@@ -0,0 +1,244 @@
+package store
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, basePath string) *Server {
+	t.Helper()
+	s, err := New(Config{
+		Addr:                   ":0",
+		BasePath:               basePath,
+		MaxAvailableSpaceBytes: 1 << 30,
+		TLSConfig:              &tls.Config{},
+		TokenSecret:            "secret",
+		TokenAudiences:         []string{"store"},
+	})
+	require.NoError(t, err)
+	return s
+}
+
+func readStoredFile(t *testing.T, s *Server, fileName string) []byte {
+	t.Helper()
+	file, err := s.openStoredFile(fileName)
+	require.NoError(t, err)
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	require.NoError(t, err)
+	return data
+}
+
+func TestWriteFileConcurrentUploads(t *testing.T) {
+	s := newTestServer(t, t.TempDir())
+
+	const uploads = 8
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		stored []string
+	)
+	for i := 0; i < uploads; i++ {
+		content := strings.Repeat(string(rune('a'+i)), 1<<16)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.writeFile("part", strings.NewReader(content))
+			if err != nil {
+				assert.ErrorIs(t, err, fs.ErrExist)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			stored = append(stored, content)
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, stored, 1)
+	require.Equal(t, stored[0], string(readStoredFile(t, s, "part")))
+	info, err := s.statStoredFile("part")
+	require.NoError(t, err)
+	require.Equal(t, int64(len(stored[0])), info.Size)
+	require.Equal(t, int64(1), s.usage.filesCount.Load())
+	metaInfo, err := os.Stat(s.metaPath("part"))
+	require.NoError(t, err)
+	require.Equal(t, int64(len(stored[0]))+metaInfo.Size(), s.usage.usedBytes.Load())
+}
+
+func TestScanCountsSidecars(t *testing.T) {
+	basePath := t.TempDir()
+	s := newTestServer(t, basePath)
+	require.NoError(t, s.writeFile("part", strings.NewReader("content")))
+	require.NoError(t, s.writeFile("another", strings.NewReader("another content")))
+	require.NoError(t, s.removeFile("another"))
+	used := s.usage.usedBytes.Load()
+	require.Greater(t, used, int64(len("content")))
+
+	scanned := newTestServer(t, basePath)
+	require.Equal(t, used, scanned.usage.usedBytes.Load())
+	require.Equal(t, int64(1), scanned.usage.filesCount.Load())
+	require.Equal(t, int64(len("content")), scanned.usage.logicalBytes.Load())
+}
+
+func TestRemoveFileConcurrently(t *testing.T) {
+	s := newTestServer(t, t.TempDir())
+	require.NoError(t, s.writeFile("part", strings.NewReader("content")))
+
+	var (
+		wg      sync.WaitGroup
+		removed atomic.Int64
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.removeFile("part")
+			if err != nil {
+				assert.ErrorIs(t, err, fs.ErrNotExist)
+				return
+			}
+			removed.Add(1)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int64(1), removed.Load())
+	require.Zero(t, s.usage.filesCount.Load())
+	require.Zero(t, s.usage.usedBytes.Load())
+	require.Zero(t, s.usage.logicalBytes.Load())
+}
+
+func TestWriteFileKeepsExistingSidecar(t *testing.T) {
+	s := newTestServer(t, t.TempDir())
+	require.NoError(t, s.writeFile("part", strings.NewReader("first")))
+
+	err := s.writeFile("part", strings.NewReader("second"))
+	require.ErrorIs(t, err, fs.ErrExist)
+
+	meta, err := s.readMeta("part")
+	require.NoError(t, err)
+	require.Equal(t, int64(len("first")), meta.Size)
+	require.Equal(t, []byte("first"), readStoredFile(t, s, "part"))
+}
+
+func TestWriteFileFailedUploadLeavesNothing(t *testing.T) {
+	s := newTestServer(t, t.TempDir())
+
+	errBroken := errors.New("broken body")
+	err := s.writeFile("part", io.MultiReader(bytes.NewReader([]byte("data")), &failingReader{err: errBroken}))
+	require.ErrorIs(t, err, errBroken)
+
+	_, err = s.openStoredFile("part")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = s.readMeta("part")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.Zero(t, s.usage.usedBytes.Load())
+}
+
+func TestWriteMetaKeepsExistingSidecar(t *testing.T) {
+	s := newTestServer(t, t.TempDir())
+	require.NoError(t, s.writeFile("part", strings.NewReader("content")))
+	uploaded, err := s.readMeta("part")
+	require.NoError(t, err)
+
+	err = s.writeMeta("part", fileMeta{Size: 1, Checksum: "other", Codec: CompressionNone})
+	require.ErrorIs(t, err, fs.ErrExist)
+	meta, err := s.readMeta("part")
+	require.NoError(t, err)
+	require.Equal(t, uploaded, meta)
+}
+
+func TestSweepOrphanMeta(t *testing.T) {
+	basePath := t.TempDir()
+	s := newTestServer(t, basePath)
+	require.NoError(t, s.writeMeta("part", fileMeta{Size: 1, Checksum: "stale", Codec: CompressionNone}))
+	require.ErrorIs(t, s.writeFile("part", strings.NewReader("content")), fs.ErrExist)
+
+	s = newTestServer(t, basePath)
+	require.NoError(t, s.writeFile("part", strings.NewReader("content")))
+	require.Equal(t, []byte("content"), readStoredFile(t, s, "part"))
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestMigrateFlatLayout(t *testing.T) {
+	basePath := t.TempDir()
+	s := newTestServer(t, basePath)
+	require.NoError(t, s.writeFile("existing", strings.NewReader("sharded")))
+
+	// clashing file has name of shard directory which is needed for the other flat file
+	clashing := filepath.Base(filepath.Dir(filepath.Dir(s.filePath("part"))))
+	require.NoError(t, os.RemoveAll(filepath.Join(basePath, clashing)))
+	flat := map[string]string{
+		clashing:   "clashing",
+		"existing": "flat",
+		"part":     "content",
+	}
+	for name, content := range flat {
+		require.NoError(t, os.WriteFile(filepath.Join(basePath, name), []byte(content), 0o644))
+	}
+
+	s = newTestServer(t, basePath)
+	require.Equal(t, []byte("clashing"), readStoredFile(t, s, clashing))
+	require.Equal(t, []byte("content"), readStoredFile(t, s, "part"))
+	require.Equal(t, []byte("sharded"), readStoredFile(t, s, "existing"))
+
+	// file which exists in sharded layout is not replaced and is left in flat layout
+	data, err := os.ReadFile(filepath.Join(basePath, "existing"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("flat"), data)
+	_, err = os.Stat(filepath.Join(basePath, "part"))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = os.Stat(filepath.Join(basePath, migrateDirName))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestListStoredFilesPages(t *testing.T) {
+	s := newTestServer(t, t.TempDir())
+	var uploaded []string
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("file-%d.0", i)
+		require.NoError(t, s.writeFile(name, strings.NewReader(name)))
+		uploaded = append(uploaded, name)
+	}
+
+	var listed []string
+	after := ""
+	for {
+		names, err := s.listStoredFiles(after, 7)
+		require.NoError(t, err)
+		if len(names) == 0 {
+			break
+		}
+		require.LessOrEqual(t, len(names), 7)
+		listed = append(listed, names...)
+		after = names[len(names)-1]
+	}
+	require.ElementsMatch(t, uploaded, listed)
+
+	// name which is not stored anymore is still valid cursor
+	require.NoError(t, s.removeFile(listed[10]))
+	names, err := s.listStoredFiles(listed[10], 5)
+	require.NoError(t, err)
+	require.Equal(t, listed[11:16], names)
+}
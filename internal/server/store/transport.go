@@ -4,33 +4,37 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"io"
+	"io/fs"
 	"log/slog"
 	"net/http"
 	"net/http/pprof"
-	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/itimofeev/yas3/internal/provider/auth"
 )
 
+var errReadOnly = errors.New("store server is in read-only mode")
+
 func (s *Server) uploadFile(resp http.ResponseWriter, req *http.Request) {
-	fileName := chi.URLParam(req, "fileName")
-	file, err := os.OpenFile(s.cfg.BasePath+"/"+fileName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
-	if err != nil {
-		s.error(req, resp, err)
+	if s.readOnly.Load() {
+		s.error(req, resp, errReadOnly)
 		return
 	}
-	defer file.Close()
 
-	_, err = io.Copy(file, req.Body)
-	if err != nil {
-		s.error(req, resp, err)
+	// check declared size before creating file, real size is checked by quotaWriter while streaming
+	stats := s.usage.stats(s.cfg.BasePath, int64(s.cfg.MaxAvailableSpaceBytes))
+	if req.ContentLength > stats.Total-stats.Used {
+		s.error(req, resp, errQuotaExceeded)
 		return
 	}
-	if err := file.Sync(); err != nil {
+
+	fileName := chi.URLParam(req, "fileName")
+	if err := s.writeFile(fileName, req.Body); err != nil {
 		s.error(req, resp, err)
 		return
 	}
@@ -40,36 +44,128 @@ func (s *Server) uploadFile(resp http.ResponseWriter, req *http.Request) {
 
 func (s *Server) getFile(resp http.ResponseWriter, req *http.Request) {
 	fileName := chi.URLParam(req, "fileName")
-	file, err := os.OpenFile(s.cfg.BasePath+"/"+fileName, os.O_RDONLY, 0o600)
+	file, err := s.openStoredFile(fileName)
 	if err != nil {
 		s.error(req, resp, err)
 		return
 	}
 	defer file.Close()
 
-	_, err = io.Copy(resp, file)
+	// ServeContent handles Range and conditional headers, compressed file is decompressed only from the frame where range starts
+	resp.Header().Set("Content-Type", "application/octet-stream")
+	resp.Header().Set("Etag", file.etag())
+	http.ServeContent(resp, req, fileName, file.modTime, file)
+}
+
+func (s *Server) deleteFile(resp http.ResponseWriter, req *http.Request) {
+	if err := s.removeFile(chi.URLParam(req, "fileName")); err != nil {
+		s.error(req, resp, err)
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+// checksumHeader contains hex encoded sha256 checksum of stored file.
+const checksumHeader = "X-Checksum-Sha256"
+
+func (s *Server) headFile(resp http.ResponseWriter, req *http.Request) {
+	info, err := s.statStoredFile(chi.URLParam(req, "fileName"))
 	if err != nil {
-		slog.Warn("error while sending file", "err", err)
+		s.error(req, resp, err)
+		return
 	}
+
+	resp.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	resp.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	resp.Header().Set(checksumHeader, info.Checksum)
+	resp.WriteHeader(http.StatusOK)
 }
 
-func (s *Server) getAvailableSpace(resp http.ResponseWriter, req *http.Request) {
-	var size int64
-	err := filepath.Walk(s.cfg.BasePath, func(_ string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			size += info.Size()
+const (
+	defaultListLimit = 1000
+	maxListLimit     = 10000
+)
+
+type listResponse struct {
+	Names []string `json:"names"`
+}
+
+// listFiles returns page of stored file names ordered by shard and name, next page starts after the last returned name.
+func (s *Server) listFiles(resp http.ResponseWriter, req *http.Request) {
+	limit := defaultListLimit
+	if limitStr := req.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit <= 0 || limit > maxListLimit {
+			writeErrResponse(resp, "invalid limit "+limitStr, http.StatusBadRequest)
+			return
 		}
-		return err
-	})
+	}
 
+	names, err := s.listStoredFiles(req.URL.Query().Get("after"), limit)
 	if err != nil {
-		slog.Warn("error while sending file", "err", err)
+		s.error(req, resp, err)
+		return
 	}
 
-	_, _ = resp.Write([]byte(fmt.Sprintf(`{"total": %d, "used": %d}`, s.cfg.MaxAvailableSpaceBytes, size)))
+	writeJSONResponse(resp, listResponse{Names: names})
+}
+
+type statsResponse struct {
+	Total       int64          `json:"total"`
+	Capacity    int64          `json:"capacity"`
+	Used        int64          `json:"used"`
+	LogicalUsed int64          `json:"logicalUsed"`
+	FilesCount  int64          `json:"filesCount"`
+	InodesTotal int64          `json:"inodesTotal"`
+	InodesFree  int64          `json:"inodesFree"`
+	ReadOnly    bool           `json:"readOnly"`
+	Labels      labelsResponse `json:"labels"`
+	// CorruptedFiles are files found by scrubber which content doesn't match checksum.
+	CorruptedFiles []string `json:"corruptedFiles"`
+}
+
+type labelsResponse struct {
+	Zone string `json:"zone"`
+	Rack string `json:"rack"`
+	Host string `json:"host"`
+}
+
+func (s *Server) getStats(resp http.ResponseWriter, _ *http.Request) {
+	stats := s.usage.stats(s.cfg.BasePath, int64(s.cfg.MaxAvailableSpaceBytes))
+
+	writeJSONResponse(resp, statsResponse{
+		Total:          stats.Total,
+		Capacity:       stats.Capacity,
+		Used:           stats.Used,
+		LogicalUsed:    stats.LogicalUsed,
+		FilesCount:     stats.FilesCount,
+		InodesTotal:    stats.InodesTotal,
+		InodesFree:     stats.InodesFree,
+		ReadOnly:       s.readOnly.Load(),
+		CorruptedFiles: s.corrupted.list(),
+		Labels: labelsResponse{
+			Zone: s.cfg.Zone,
+			Rack: s.cfg.Rack,
+			Host: s.cfg.Host,
+		},
+	})
+}
+
+// setMode switches server between read-write mode and read-only mode, in which uploads are rejected.
+func (s *Server) setMode(resp http.ResponseWriter, req *http.Request) {
+	switch mode := chi.URLParam(req, "mode"); mode {
+	case ModeReadWrite:
+		s.readOnly.Store(false)
+	case ModeReadOnly:
+		s.readOnly.Store(true)
+	default:
+		writeErrResponse(resp, "unknown mode "+mode, http.StatusBadRequest)
+		return
+	}
+	slog.Info("store server mode changed", "readOnly", s.readOnly.Load())
+
+	_, _ = resp.Write([]byte("ok"))
 }
 
 func (s *Server) initRouter() http.Handler {
@@ -102,25 +198,68 @@ func (s *Server) initRouter() http.Handler {
 		r.Use(middleware.RequestID)
 		r.Use(middleware.Logger)
 		r.Route("/api/v1", func(api chi.Router) {
-			api.Post("/uploadFile/{fileName}", s.uploadFile)
-			api.Get("/getFile/{fileName}", s.getFile)
-			api.Get("/getAvailableSpace", s.getAvailableSpace)
+			api.With(s.requireToken(auth.StoreOperationUpload, "fileName")).Post("/uploadFile/{fileName}", s.uploadFile)
+			api.With(s.requireToken(auth.StoreOperationGet, "fileName")).Get("/getFile/{fileName}", s.getFile)
+			api.With(s.requireToken(auth.StoreOperationStats, "")).Get("/getStats", s.getStats)
+			api.With(s.requireToken(auth.StoreOperationSetMode, "mode")).Post("/setMode/{mode}", s.setMode)
+
+			api.With(s.requireToken(auth.StoreOperationList, "")).Get("/parts", s.listFiles)
+			api.With(s.requireToken(auth.StoreOperationStat, "fileName")).Head("/parts/{fileName}", s.headFile)
+			api.With(s.requireToken(auth.StoreOperationDelete, "fileName")).Delete("/parts/{fileName}", s.deleteFile)
 		})
 	})
 
 	return r
 }
 
+// requireToken rejects requests without bearer token for operation on file part or mode from URL param when token secret is set.
+func (s *Server) requireToken(operation, param string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			if s.cfg.TokenSecret != "" {
+				token, _ := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+				var name string
+				if param != "" {
+					name = chi.URLParam(req, param)
+				}
+				err := auth.VerifyStoreToken(s.cfg.TokenSecret, token, s.cfg.TokenAudiences, operation, name, time.Now())
+				if err != nil {
+					s.error(req, resp, err)
+					return
+				}
+			}
+			next.ServeHTTP(resp, req)
+		})
+	}
+}
+
 func (s *Server) error(_ *http.Request, w http.ResponseWriter, err error) {
 	slog.Warn("got error while handling request", "err", err)
 	switch {
 	case errors.Is(err, context.Canceled):
 		writeErrResponse(w, "timeout", http.StatusRequestTimeout)
+	case errors.Is(err, auth.ErrInvalidStoreToken):
+		writeErrResponse(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, errReadOnly):
+		writeErrResponse(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, errInvalidFileName):
+		writeErrResponse(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, fs.ErrNotExist):
+		writeErrResponse(w, "file not found", http.StatusNotFound)
+	case errors.Is(err, fs.ErrExist):
+		writeErrResponse(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, errQuotaExceeded):
+		writeErrResponse(w, err.Error(), http.StatusInsufficientStorage)
 	default:
 		writeErrResponse(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+func writeJSONResponse(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
 func writeErrResponse(w http.ResponseWriter, err string, status int) {
 	w.Header().Set("Content-type", "application/json")
 
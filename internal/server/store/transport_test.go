@@ -0,0 +1,50 @@
+package store
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/itimofeev/yas3/internal/provider/auth"
+)
+
+func TestSetModeTokenNamesMode(t *testing.T) {
+	s := newTestServer(t, t.TempDir())
+	handler := s.initRouter()
+	setMode := func(mode, tokenMode string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/setMode/"+mode, nil)
+		req.Header.Set("Authorization", "Bearer "+auth.StoreToken("secret", "store", auth.StoreOperationSetMode, tokenMode, time.Now()))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	require.Equal(t, http.StatusOK, setMode(ModeReadOnly, ModeReadOnly))
+	require.True(t, s.readOnly.Load())
+	require.Equal(t, http.StatusUnauthorized, setMode(ModeReadWrite, ModeReadOnly))
+	require.True(t, s.readOnly.Load())
+	require.Equal(t, http.StatusOK, setMode(ModeReadWrite, ModeReadWrite))
+	require.False(t, s.readOnly.Load())
+}
+
+func TestNewRequiresClientAuthentication(t *testing.T) {
+	_, err := New(Config{
+		Addr:                   ":0",
+		BasePath:               t.TempDir(),
+		MaxAvailableSpaceBytes: 1 << 30,
+		TLSConfig:              &tls.Config{},
+	})
+	require.Error(t, err)
+
+	_, err = New(Config{
+		Addr:                   ":0",
+		BasePath:               t.TempDir(),
+		MaxAvailableSpaceBytes: 1 << 30,
+		TLSConfig:              &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert},
+	})
+	require.NoError(t, err)
+}
@@ -21,6 +21,27 @@ func TestFrontServer(t *testing.T) {
 	}
 }
 
+func TestFrontServerCustomerKey(t *testing.T) {
+	ctx := context.Background()
+	frontClient, err := front.New(front.Config{BasePath: "http://localhost:8080"})
+	require.NoError(t, err)
+
+	customerKey := bytes.Repeat([]byte{1}, 32)
+	fileName := uuid.New().String()
+	originalString := generateStringOfSize(100)
+	require.NoError(t, frontClient.UploadFileWithKey(ctx, fileName, []byte(originalString), customerKey))
+
+	data, err := frontClient.GetFileWithKey(ctx, fileName, customerKey)
+	require.NoError(t, err)
+	require.Equal(t, originalString, string(data))
+
+	_, err = frontClient.GetFileWithKey(ctx, fileName, bytes.Repeat([]byte{2}, 32))
+	require.ErrorContains(t, err, "403")
+
+	_, err = frontClient.GetFile(ctx, fileName)
+	require.ErrorContains(t, err, "400")
+}
+
 func checkFileUpload(t *testing.T, fileSize int, storeClient *front.Client) {
 	ctx := context.Background()
 
@@ -11,37 +11,89 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 
+	"github.com/itimofeev/yas3/internal/entity"
 	"github.com/itimofeev/yas3/internal/provider/store"
+	"github.com/itimofeev/yas3/internal/provider/tlsconfig"
 )
 
+// storeAddrs are addresses of the same store server through every transport, tests of store client run against each of them.
+var storeAddrs = []string{"https://localhost:9090", "grpc://localhost:9190"}
+
+// newStoreClient connects to store server with certificates generated by generate-cert.sh.
+func newStoreClient(addr string) (entity.StoreClient, error) {
+	tlsConfig, err := tlsconfig.Client(tlsconfig.Config{
+		CertFile: "../temp/certs/front.pem",
+		KeyFile:  "../temp/certs/front.key",
+		CAFile:   "../temp/certs/ca.pem",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return store.NewClient(store.Config{StoreAddr: addr, TLSConfig: tlsConfig})
+}
+
 func TestClientConnect(t *testing.T) {
-	ctx := context.Background()
-	storeClient, err := store.New(store.Config{StoreAddr: "https://localhost:9090"})
-	require.NoError(t, err)
+	for _, addr := range storeAddrs {
+		t.Run(addr, func(t *testing.T) {
+			ctx := context.Background()
+			storeClient, err := newStoreClient(addr)
+			require.NoError(t, err)
 
-	fileName := "someFileName.txt"
+			fileName := uuid.New().String() + ".txt"
 
-	err = storeClient.UploadFile(ctx, fileName, strings.NewReader("hello, there!"))
-	require.NoError(t, err)
+			err = storeClient.UploadFile(ctx, fileName, strings.NewReader("hello, there!"))
+			require.NoError(t, err)
 
-	resp, err := storeClient.GetFile(ctx, fileName)
-	if err != nil {
-		panic(err)
-	}
-	defer resp.Close()
+			resp, err := storeClient.GetFile(ctx, fileName)
+			if err != nil {
+				panic(err)
+			}
+			defer resp.Close()
 
-	body := &bytes.Buffer{}
-	_, err = io.Copy(body, resp)
-	if err != nil {
-		panic(err)
+			body := &bytes.Buffer{}
+			_, err = io.Copy(body, resp)
+			if err != nil {
+				panic(err)
+			}
+
+			log.Printf("Body length: %d bytes \n", body.Len())
+			log.Printf("Response body %s \n", body.Bytes())
+
+			stats, err := storeClient.GetStats(ctx)
+			require.NoError(t, err)
+			fmt.Println(stats)
+		})
 	}
+}
+
+func TestStoreParts(t *testing.T) {
+	for _, addr := range storeAddrs {
+		t.Run(addr, func(t *testing.T) {
+			ctx := context.Background()
+			storeClient, err := newStoreClient(addr)
+			require.NoError(t, err)
 
-	log.Printf("Body length: %d bytes \n", body.Len())
-	log.Printf("Response body %s \n", body.Bytes())
+			fileName := uuid.New().String() + ".0"
+			err = storeClient.UploadFile(ctx, fileName, strings.NewReader("hello, there!"))
+			require.NoError(t, err)
 
-	available, err := storeClient.GetAvailableSpace(ctx)
-	require.NoError(t, err)
-	fmt.Println(available)
+			info, err := storeClient.StatFile(ctx, fileName)
+			require.NoError(t, err)
+			require.Equal(t, int64(len("hello, there!")), info.Size)
+			require.NotEmpty(t, info.Checksum)
+
+			names, err := storeClient.ListFiles(ctx, "", 1)
+			require.NoError(t, err)
+			require.Len(t, names, 1)
+
+			err = storeClient.DeleteFile(ctx, fileName)
+			require.NoError(t, err)
+
+			_, err = storeClient.StatFile(ctx, fileName)
+			require.ErrorIs(t, err, entity.ErrFileNotFound)
+		})
+	}
 }